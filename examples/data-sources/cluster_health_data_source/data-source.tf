@@ -0,0 +1,8 @@
+data "talos_cluster_health" "example" {
+  endpoint            = "<ip address>"
+  machine_ca          = "cert autority"
+  machine_crt         = "cert"
+  machine_key         = "key"
+  control_plane_nodes = ["<ip address>"]
+  worker_nodes        = ["<ip address>", "<ip address>"]
+}
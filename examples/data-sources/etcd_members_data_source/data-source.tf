@@ -0,0 +1,6 @@
+data "talos_etcd_members" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+}
@@ -0,0 +1,7 @@
+data "talos_machine_disks" "example" {
+  endpoint = "<ip address>"
+  filter = {
+    type     = "NVME"
+    min_size = 100000000000
+  }
+}
@@ -0,0 +1,8 @@
+data "talos_machine_file" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  path        = "/system/state/config.yaml"
+  sensitive   = true
+}
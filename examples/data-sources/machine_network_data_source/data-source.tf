@@ -0,0 +1,8 @@
+data "talos_machine_network" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  links       = ["eth0"]
+  family      = "inet4"
+}
@@ -0,0 +1,11 @@
+data "talos_resources" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  type        = "members"
+}
+
+output "members" {
+  value = [for member in data.talos_resources.example.resources : jsondecode(member.spec_json).hostname]
+}
@@ -0,0 +1,7 @@
+data "talos_version" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  nodes       = ["<ip address>", "<ip address>"]
+}
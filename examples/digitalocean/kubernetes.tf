@@ -31,14 +31,17 @@ provider "kubernetes" {
   cluster_ca_certificate = talos_bootstrap.digitalocean.cluster_ca_certificate
 }
 
-resource "time_sleep" "wait_60_seconds" {
-  depends_on = [talos_bootstrap.digitalocean]
-
-  create_duration = "60s"
+data "talos_cluster_health" "digitalocean" {
+  endpoint            = talos_bootstrap.digitalocean.endpoint
+  machine_ca          = base64decode(local.talos_config.ca)
+  machine_crt         = base64decode(local.talos_config.crt)
+  machine_key         = base64decode(local.talos_config.key)
+  control_plane_nodes = digitalocean_droplet.control_plane[*].ipv4_address
+  worker_nodes        = digitalocean_droplet.worker[*].ipv4_address
 }
 
 data "kubernetes_all_namespaces" "allns" {
-  depends_on = [time_sleep.wait_60_seconds]
+  depends_on = [data.talos_cluster_health.digitalocean]
 }
 
 output "all-ns" {
@@ -21,14 +21,17 @@ provider "kubernetes" {
   cluster_ca_certificate = talos_bootstrap.openstack.cluster_ca_certificate
 }
 
-resource "time_sleep" "wait_60_seconds" {
-  depends_on = [talos_bootstrap.openstack]
-
-  create_duration = "60s"
+data "talos_cluster_health" "openstack" {
+  endpoint            = talos_bootstrap.openstack.endpoint
+  machine_ca          = base64decode(local.talos_config.contexts.openstack.ca)
+  machine_crt         = base64decode(local.talos_config.contexts.openstack.crt)
+  machine_key         = base64decode(local.talos_config.contexts.openstack.key)
+  control_plane_nodes = openstack_compute_instance_v2.talos_control_plane[*].access_ip_v4
+  worker_nodes        = openstack_compute_instance_v2.talos_worker[*].access_ip_v4
 }
 
 data "kubernetes_all_namespaces" "allns" {
-  depends_on = [time_sleep.wait_60_seconds]
+  depends_on = [data.talos_cluster_health.openstack]
 }
 
 output "all-ns" {
@@ -0,0 +1,7 @@
+resource "talos_etcd_member" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  node        = "<ip address>"
+}
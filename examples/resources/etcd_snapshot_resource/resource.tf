@@ -0,0 +1,10 @@
+resource "talos_etcd_snapshot" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  path        = "etcd.snapshot"
+  triggers = {
+    kubernetes_version = "1.25.4"
+  }
+}
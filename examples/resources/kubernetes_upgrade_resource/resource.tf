@@ -0,0 +1,9 @@
+resource "talos_kubernetes_upgrade" "example" {
+  endpoint            = "<ip address>"
+  machine_ca          = "cert autority"
+  machine_crt         = "cert"
+  machine_key         = "key"
+  control_plane_nodes = ["<ip address>"]
+  worker_nodes        = ["<ip address>", "<ip address>"]
+  kubernetes_version  = "1.25.4"
+}
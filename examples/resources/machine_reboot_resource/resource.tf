@@ -0,0 +1,10 @@
+resource "talos_machine_reboot" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  node        = "<ip address>"
+  triggers = {
+    kernel_args = "console=ttyS0"
+  }
+}
@@ -0,0 +1,11 @@
+resource "talos_machine_service" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  nodes       = ["<ip address>", "<ip address>"]
+  service     = "kubelet"
+  triggers = {
+    registry_password = sha256("password")
+  }
+}
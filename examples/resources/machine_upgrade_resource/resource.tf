@@ -0,0 +1,8 @@
+resource "talos_machine_upgrade" "example" {
+  endpoint    = "<ip address>"
+  machine_ca  = "cert autority"
+  machine_crt = "cert"
+  machine_key = "key"
+  nodes       = ["<ip address>", "<ip address>"]
+  image       = "ghcr.io/siderolabs/installer:v1.2.4"
+}
@@ -0,0 +1,61 @@
+package attribute_plan_modifier
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+)
+
+// RequiresReplaceIfFunc tells whether the change of an attribute requires the
+// replacement of the resource. It is only called when the planned value
+// differs from the prior state, and may look at the rest of the
+// configuration.
+type RequiresReplaceIfFunc func(ctx context.Context, req tfsdk.ModifyAttributePlanRequest) (bool, diag.Diagnostics)
+
+// requiresReplaceIfAttributePlanModifier requires the replacement of the
+// resource when an attribute changes and a condition holds.
+type requiresReplaceIfAttributePlanModifier struct {
+	description string
+	condition   RequiresReplaceIfFunc
+}
+
+// RequiresReplaceIf is an helper to instantiate a
+// requiresReplaceIfAttributePlanModifier. The description tells when the
+// resource is replaced.
+func RequiresReplaceIf(description string, condition RequiresReplaceIfFunc) tfsdk.AttributePlanModifier {
+	return &requiresReplaceIfAttributePlanModifier{description, condition}
+}
+
+var _ tfsdk.AttributePlanModifier = (*requiresReplaceIfAttributePlanModifier)(nil)
+
+func (apm *requiresReplaceIfAttributePlanModifier) Description(ctx context.Context) string {
+	return apm.MarkdownDescription(ctx)
+}
+
+func (apm *requiresReplaceIfAttributePlanModifier) MarkdownDescription(ctx context.Context) string {
+	return "Requires the replacement of the resource " + apm.description
+}
+
+func (apm *requiresReplaceIfAttributePlanModifier) Modify(ctx context.Context, req tfsdk.ModifyAttributePlanRequest, res *tfsdk.ModifyAttributePlanResponse) {
+	// Nothing to replace when the resource is created or destroyed
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	// A previous plan modifier in the sequence may have already required the
+	// replacement, or kept the prior state
+	if res.RequiresReplace || req.AttributePlan.Equal(req.AttributeState) {
+		return
+	}
+
+	// The value is only known at apply time, it may differ
+	if req.AttributePlan.IsUnknown() {
+		res.RequiresReplace = true
+		return
+	}
+
+	requiresReplace, diags := apm.condition(ctx, req)
+	res.Diagnostics.Append(diags...)
+	res.RequiresReplace = requiresReplace
+}
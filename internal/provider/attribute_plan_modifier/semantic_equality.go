@@ -0,0 +1,154 @@
+package attribute_plan_modifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"gopkg.in/yaml.v3"
+)
+
+// semanticEqualityAttributePlanModifier keeps the prior state of a string
+// attribute, or of a list of strings, when the planned value is equivalent,
+// to avoid spurious diffs.
+type semanticEqualityAttributePlanModifier struct {
+	description string
+	equal       func(a, b string) bool
+}
+
+// SemanticEquality is an helper to instantiate a
+// semanticEqualityAttributePlanModifier comparing YAML or JSON documents.
+func SemanticEquality() tfsdk.AttributePlanModifier {
+	return &semanticEqualityAttributePlanModifier{"YAML or JSON documents", YAMLEqual}
+}
+
+// NormalizePEM is an helper to instantiate a
+// semanticEqualityAttributePlanModifier comparing PEM-encoded blocks.
+func NormalizePEM() tfsdk.AttributePlanModifier {
+	return &semanticEqualityAttributePlanModifier{"PEM-encoded blocks", PEMEqual}
+}
+
+var _ tfsdk.AttributePlanModifier = (*semanticEqualityAttributePlanModifier)(nil)
+
+func (apm *semanticEqualityAttributePlanModifier) Description(ctx context.Context) string {
+	return apm.MarkdownDescription(ctx)
+}
+
+func (apm *semanticEqualityAttributePlanModifier) MarkdownDescription(ctx context.Context) string {
+	return "Keeps the prior state if the planned " + apm.description + " are equivalent"
+}
+
+func (apm *semanticEqualityAttributePlanModifier) Modify(_ context.Context, req tfsdk.ModifyAttributePlanRequest, res *tfsdk.ModifyAttributePlanResponse) {
+	if req.AttributeState == nil || req.AttributeState.IsNull() || req.AttributePlan.IsNull() || req.AttributePlan.IsUnknown() {
+		return
+	}
+
+	if apm.equivalent(req.AttributeState, req.AttributePlan) {
+		res.AttributePlan = req.AttributeState
+	}
+}
+
+func (apm *semanticEqualityAttributePlanModifier) equivalent(state, plan attr.Value) bool {
+	switch plan := plan.(type) {
+	case types.String:
+		state, ok := state.(types.String)
+		return ok && apm.equal(state.Value, plan.Value)
+	case types.List:
+		state, ok := state.(types.List)
+		if !ok || len(state.Elems) != len(plan.Elems) {
+			return false
+		}
+		for i := range plan.Elems {
+			if !apm.equivalent(state.Elems[i], plan.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// YAMLEqual tells whether two YAML streams, or JSON documents, hold the same
+// documents.
+func YAMLEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	aDocs, err := decodeYAML(a)
+	if err != nil {
+		return false
+	}
+	bDocs, err := decodeYAML(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(aDocs, bDocs)
+}
+
+// decodeYAML decodes all the documents of a YAML stream.
+func decodeYAML(in string) ([]interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(strings.NewReader(in))
+	for {
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// PEMEqual tells whether two strings hold the same PEM-encoded blocks,
+// ignoring line breaks, indentation and text between the blocks.
+func PEMEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	aBlocks, bBlocks := decodePEM(a), decodePEM(b)
+	if len(aBlocks) == 0 || len(aBlocks) != len(bBlocks) {
+		return false
+	}
+	for i := range aBlocks {
+		if aBlocks[i].Type != bBlocks[i].Type || !bytes.Equal(aBlocks[i].Bytes, bBlocks[i].Bytes) {
+			return false
+		}
+	}
+	return true
+}
+
+// TrimPEM removes the indentation and the trailing spaces of the lines of
+// PEM-encoded blocks, as found in heredocs, which prevent decoding them.
+func TrimPEM(in string) string {
+	lines := strings.Split(in, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// decodePEM decodes all the PEM-encoded blocks of a string, whose lines may
+// be indented.
+func decodePEM(in string) []*pem.Block {
+	var blocks []*pem.Block
+	rest := []byte(TrimPEM(in))
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return blocks
+		}
+		blocks = append(blocks, block)
+	}
+}
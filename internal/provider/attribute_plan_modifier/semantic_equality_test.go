@@ -0,0 +1,71 @@
+package attribute_plan_modifier
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestYAMLEqual(t *testing.T) {
+	for _, tt := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"machine:\n  install:\n    disk: /dev/sda\n", "machine: {install: {disk: /dev/sda}}", true},
+		{`[{"op": "add", "path": "/a", "value": 1}]`, "- op: add\n  path: /a\n  value: 1\n", true},
+		{"a: 1\n---\nb: 2\n", "a: 1\n---\nb: 2\n\n", true},
+		{"a: 1\n", "a: 2\n", false},
+		{"a: 1\n---\nb: 2\n", "a: 1\n", false},
+		{"@patch.yaml", "@other.yaml", false},
+	} {
+		if got := YAMLEqual(tt.a, tt.b); got != tt.equal {
+			t.Errorf("YAMLEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
+
+const testPEM = "-----BEGIN CERTIFICATE-----\nMIIBAzCBqqADAgECAgEBMAoGCCqGSM49BAMCMAAwHhcNMjIxMTAxMDAwMDAwWhcN\n-----END CERTIFICATE-----\n"
+
+func TestPEMEqual(t *testing.T) {
+	for _, tt := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{testPEM, "\n  " + testPEM + "\n", true},
+		{testPEM, "-----BEGIN CERTIFICATE-----\r\nMIIBAzCBqqADAgECAgEBMAoGCCqGSM49BAMCMAAwHhcNMjIxMTAxMDAwMDAwWhcN\r\n-----END CERTIFICATE-----\r\n", true},
+		{testPEM, testPEM + testPEM, false},
+		{testPEM, "-----BEGIN CERTIFICATE-----\nMIIBAzCBqqADAgECAgEBMAoGCCqGSM49BAMCMAAwHhcNMjIxMTAxMDAwMDAwWhcO\n-----END CERTIFICATE-----\n", false},
+		{"not PEM", "not PEM ", false},
+	} {
+		if got := PEMEqual(tt.a, tt.b); got != tt.equal {
+			t.Errorf("PEMEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
+
+func TestSemanticEqualityModify(t *testing.T) {
+	state := types.List{ElemType: types.StringType, Elems: []attr.Value{types.String{Value: "a: 1\n"}}}
+	plan := types.List{ElemType: types.StringType, Elems: []attr.Value{types.String{Value: "a:   1"}}}
+
+	res := &tfsdk.ModifyAttributePlanResponse{AttributePlan: plan}
+	SemanticEquality().Modify(context.Background(), tfsdk.ModifyAttributePlanRequest{
+		AttributeState: state,
+		AttributePlan:  plan,
+	}, res)
+	if !res.AttributePlan.Equal(state) {
+		t.Errorf("expected the prior state to be kept, got %v", res.AttributePlan)
+	}
+
+	plan.Elems = []attr.Value{types.String{Value: "a: 2"}}
+	res = &tfsdk.ModifyAttributePlanResponse{AttributePlan: plan}
+	SemanticEquality().Modify(context.Background(), tfsdk.ModifyAttributePlanRequest{
+		AttributeState: state,
+		AttributePlan:  plan,
+	}, res)
+	if !res.AttributePlan.Equal(plan) {
+		t.Errorf("expected the plan to be kept, got %v", res.AttributePlan)
+	}
+}
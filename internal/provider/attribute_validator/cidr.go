@@ -0,0 +1,15 @@
+package attribute_validator
+
+import (
+	"net/netip"
+
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+)
+
+// CIDR checks that a string is an IPv4 or IPv6 prefix in CIDR notation.
+func CIDR() tfsdk.AttributeValidator {
+	return StringFunc("a CIDR (e.g. 10.0.0.0/8)", func(value string) error {
+		_, err := netip.ParsePrefix(value)
+		return err
+	})
+}
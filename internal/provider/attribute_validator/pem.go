@@ -0,0 +1,59 @@
+package attribute_validator
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+)
+
+// PEMCertificate checks that a string holds one or more PEM-encoded X.509
+// certificates.
+func PEMCertificate() tfsdk.AttributeValidator {
+	return StringFunc("PEM-encoded certificates", func(value string) error {
+		rest := []byte(attribute_plan_modifier.TrimPEM(value))
+		for n := 0; ; n++ {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				if n == 0 {
+					return fmt.Errorf("no PEM block found")
+				}
+				return nil
+			}
+			if block.Type != "CERTIFICATE" {
+				return fmt.Errorf("unexpected PEM block %q", block.Type)
+			}
+			if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// PEMPrivateKey checks that a string holds a PEM-encoded private key.
+func PEMPrivateKey() tfsdk.AttributeValidator {
+	return StringFunc("a PEM-encoded private key", func(value string) error {
+		block, _ := pem.Decode([]byte(attribute_plan_modifier.TrimPEM(value)))
+		if block == nil {
+			return fmt.Errorf("no PEM block found")
+		}
+		if block.Type != "PRIVATE KEY" && !strings.HasSuffix(block.Type, " PRIVATE KEY") {
+			return fmt.Errorf("unexpected PEM block %q", block.Type)
+		}
+
+		if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			return nil
+		}
+		if _, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+			return nil
+		}
+		if _, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return nil
+		}
+		return fmt.Errorf("unsupported private key")
+	})
+}
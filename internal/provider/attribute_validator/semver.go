@@ -0,0 +1,17 @@
+package attribute_validator
+
+import (
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+)
+
+// Semver checks that a string is a semantic version, optionally prefixed with
+// "v".
+func Semver() tfsdk.AttributeValidator {
+	return StringFunc("a semantic version (e.g. 1.25.4)", func(value string) error {
+		_, err := semver.Parse(strings.TrimPrefix(value, "v"))
+		return err
+	})
+}
@@ -0,0 +1,66 @@
+package attribute_validator
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// stringFuncAttributeValidator validates a string attribute, or each element
+// of a list or map of strings, with a function.
+type stringFuncAttributeValidator struct {
+	description string
+	validate    func(string) error
+}
+
+// StringFunc is an helper to instantiate a stringFuncAttributeValidator. The
+// description tells what a valid value is.
+func StringFunc(description string, validate func(string) error) tfsdk.AttributeValidator {
+	return &stringFuncAttributeValidator{description, validate}
+}
+
+var _ tfsdk.AttributeValidator = (*stringFuncAttributeValidator)(nil)
+
+func (av *stringFuncAttributeValidator) Description(ctx context.Context) string {
+	return av.MarkdownDescription(ctx)
+}
+
+func (av *stringFuncAttributeValidator) MarkdownDescription(ctx context.Context) string {
+	return "Value must be " + av.description
+}
+
+func (av *stringFuncAttributeValidator) Validate(ctx context.Context, req tfsdk.ValidateAttributeRequest, res *tfsdk.ValidateAttributeResponse) {
+	switch value := req.AttributeConfig.(type) {
+	case types.String:
+		av.validateString(req.AttributePath, value, res)
+	case types.List:
+		for i, elem := range value.Elems {
+			if s, ok := elem.(types.String); ok {
+				av.validateString(req.AttributePath.AtListIndex(i), s, res)
+			}
+		}
+	case types.Map:
+		for key, elem := range value.Elems {
+			if s, ok := elem.(types.String); ok {
+				av.validateString(req.AttributePath.AtMapKey(key), s, res)
+			}
+		}
+	}
+}
+
+func (av *stringFuncAttributeValidator) validateString(p path.Path, value types.String, res *tfsdk.ValidateAttributeResponse) {
+	// Unknown values are validated once they are known
+	if value.Null || value.Unknown {
+		return
+	}
+
+	if err := av.validate(value.Value); err != nil {
+		res.Diagnostics.AddAttributeError(
+			p,
+			"Invalid attribute value",
+			"Value must be "+av.description+": "+err.Error(),
+		)
+	}
+}
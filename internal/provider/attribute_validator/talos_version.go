@@ -0,0 +1,16 @@
+package attribute_validator
+
+import (
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/talos-systems/talos/pkg/machinery/config"
+)
+
+// TalosVersion checks that a string is a Talos version a config generation
+// contract can be parsed from. Versions newer than the ones known to the
+// machinery are accepted, and get the features of the current contract.
+func TalosVersion() tfsdk.AttributeValidator {
+	return StringFunc("a Talos version (e.g. v1.2)", func(value string) error {
+		_, err := config.ParseContractFromVersion(value)
+		return err
+	})
+}
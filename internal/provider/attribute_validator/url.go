@@ -0,0 +1,49 @@
+package attribute_validator
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+)
+
+// URL checks that a string is an absolute URL with one of the given schemes,
+// and an explicit port if requirePort is set.
+func URL(requirePort bool, schemes ...string) tfsdk.AttributeValidator {
+	description := "a URL with scheme " + strings.Join(schemes, " or ")
+	if requirePort {
+		description += " and a port"
+	}
+
+	return StringFunc(description, func(value string) error {
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+
+		validScheme := false
+		for _, scheme := range schemes {
+			validScheme = validScheme || u.Scheme == scheme
+		}
+		if !validScheme {
+			return fmt.Errorf("unsupported scheme %q", u.Scheme)
+		}
+		if u.Hostname() == "" {
+			return fmt.Errorf("missing host")
+		}
+
+		port := u.Port()
+		if port == "" {
+			if requirePort {
+				return fmt.Errorf("missing port")
+			}
+			return nil
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port %q", port)
+		}
+		return nil
+	})
+}
@@ -2,18 +2,18 @@ package provider
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
-	"fmt"
+	"errors"
+	"io"
+	"os"
 
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/common"
 	"github.com/talos-systems/talos/pkg/machinery/api/machine"
-	"github.com/talos-systems/talos/pkg/machinery/constants"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
 )
 
 var _ resource.Resource = &BootstrapResource{}
@@ -24,20 +24,76 @@ func NewBootstrapResource() resource.Resource {
 
 type BootstrapResource struct{}
 
-type BootstrapResourceModel = KubeconfigDataSourceModel
+type BootstrapResourceModel struct {
+	ID                   types.String `tfsdk:"id"`
+	Endpoint             types.String `tfsdk:"endpoint"`
+	Endpoints            types.List   `tfsdk:"endpoints"`
+	MachineCa            types.String `tfsdk:"machine_ca"`
+	MachineCrt           types.String `tfsdk:"machine_crt"`
+	MachineKey           types.String `tfsdk:"machine_key"`
+	Node                 types.String `tfsdk:"node"`
+	ProxyURL             types.String `tfsdk:"proxy_url"`
+	SupportBundleDir     types.String `tfsdk:"support_bundle_dir"`
+	RecoverFromSnapshot  types.String `tfsdk:"recover_from_snapshot"`
+	RecoverSkipHashCheck types.Bool   `tfsdk:"recover_skip_hash_check"`
+	ClientCertificate    types.String `tfsdk:"client_certificate"`
+	ClientKey            types.String `tfsdk:"client_key"`
+	ClusterCaCertificate types.String `tfsdk:"cluster_ca_certificate"`
+	Raw                  types.String `tfsdk:"raw"`
+}
 
 func (r *BootstrapResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_bootstrap"
 }
 
 func (r *BootstrapResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	bootstrapAttributes := map[string]tfsdk.Attribute{
+		"id": {
+			Computed:            true,
+			MarkdownDescription: "Identifier, set to `endpoint`.",
+			PlanModifiers: []tfsdk.AttributePlanModifier{
+				resource.UseStateForUnknown(),
+			},
+			Type: types.StringType,
+		},
+		"recover_from_snapshot": {
+			MarkdownDescription: "Path of a local etcd snapshot to recover the cluster from, instead of bootstrapping an empty etcd. Only used when the cluster is bootstrapped.",
+			Optional:            true,
+			Type:                types.StringType,
+		},
+		"recover_skip_hash_check": {
+			Computed:            true,
+			MarkdownDescription: "Skip the integrity check of the snapshot, needed when recovering from a copy of the etcd data directory.",
+			Optional:            true,
+			PlanModifiers: []tfsdk.AttributePlanModifier{
+				attribute_plan_modifier.DefaultValue(types.Bool{Value: false}),
+			},
+			Type: types.BoolType,
+		},
+	}
+	for name, attr := range attributes {
+		bootstrapAttributes[name] = attr
+	}
+	node := bootstrapAttributes["node"]
+	node.PlanModifiers = []tfsdk.AttributePlanModifier{
+		resource.RequiresReplace(),
+	}
+	bootstrapAttributes["node"] = node
+	bootstrapAttributes["endpoint"] = targetEndpointAttribute("node")
+
 	return tfsdk.Schema{
 		MarkdownDescription: "Bootstrap a Talos cluster and download kubeconfig.",
 
-		Attributes: attributes,
+		Attributes: bootstrapAttributes,
 	}, nil
 }
 
+func (r *BootstrapResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		clientCredentialsValidator{adminRoles},
+	}
+}
+
 func (r *BootstrapResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data *BootstrapResourceModel
 
@@ -48,68 +104,61 @@ func (r *BootstrapResource) Create(ctx context.Context, req resource.CreateReque
 		return
 	}
 
-	clientCert, err := tls.X509KeyPair(
-		[]byte(data.MachineCrt.Value),
-		[]byte(data.MachineKey.Value),
-	)
+	proxy, err := withProxy(data.ProxyURL.Value)
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Error parsing key pair",
+			"Error parsing proxy URL",
 			err.Error(),
 		)
 		return
 	}
 
-	certPool := x509.NewCertPool()
-	if !certPool.AppendCertsFromPEM([]byte(data.MachineCa.Value)) {
-		resp.Diagnostics.AddError(
-			"failed to add server CA's certificate",
-			"",
-		)
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey, proxy)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+	nodeCtx := nodeContext(ctx, data.Node.Value)
 
-	tlsCredentials := credentials.NewTLS(&tls.Config{
-		Certificates: []tls.Certificate{clientCert},
-		RootCAs:      certPool,
-	})
+	bootstrapRequest := &machine.BootstrapRequest{}
+	if !data.RecoverFromSnapshot.Null {
+		if err := uploadEtcdSnapshot(nodeCtx, client, data.RecoverFromSnapshot.Value); err != nil {
+			addTalosError(&resp.Diagnostics, err, "Error uploading etcd snapshot", err.Error)
+			return
+		}
 
-	conn, err := grpc.Dial(
-		fmt.Sprintf("%s:%d", data.Endpoint.Value, constants.ApidPort),
-		grpc.WithTransportCredentials(tlsCredentials),
-		grpc.WithBlock(),
-	)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error creating gRPC connection",
-			err.Error(),
-		)
-		return
+		bootstrapRequest.RecoverEtcd = true
+		bootstrapRequest.RecoverSkipHashCheck = data.RecoverSkipHashCheck.Value
 	}
-	defer conn.Close()
-	client := machine.NewMachineServiceClient(conn)
 
-	if _, err := client.Bootstrap(ctx, &machine.BootstrapRequest{}); err != nil {
-		resp.Diagnostics.AddError(
-			"Error in bootstrap request",
-			err.Error(),
-		)
+	if _, err := client.Bootstrap(nodeCtx, bootstrapRequest); err != nil {
+		addTalosError(&resp.Diagnostics, err, "Error in bootstrap request", func() string {
+			return withDiagnostics(nodeCtx, client, data.SupportBundleDir.Value, err)
+		})
 		return
 	}
 
-	if err := kubeconfigRead(ctx, client, data); err != nil {
-		resp.Diagnostics.AddError(
-			"Error reading kubeconfig",
-			err.Error(),
-		)
+	var kubeconfig KubeconfigDataSourceModel
+	if err := kubeconfigRead(nodeCtx, client, &kubeconfig); err != nil {
+		addTalosError(&resp.Diagnostics, err, "Error reading kubeconfig", func() string {
+			return withDiagnostics(nodeCtx, client, data.SupportBundleDir.Value, err)
+		})
 		return
 	}
+	data.ClientCertificate = kubeconfig.ClientCertificate
+	data.ClientKey = kubeconfig.ClientKey
+	data.ClusterCaCertificate = kubeconfig.ClusterCaCertificate
+	data.Raw = kubeconfig.Raw
 
 	// Write logs using the tflog package
 	// Documentation: https://terraform.io/plugin/log
 	tflog.Trace(ctx, "created a Talos bootstrap resource")
 
 	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
 	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
@@ -151,3 +200,46 @@ func (r *BootstrapResource) Delete(ctx context.Context, req resource.DeleteReque
 		return
 	}
 }
+
+// uploadEtcdSnapshot uploads the etcd snapshot at path to the node, to be
+// recovered by the bootstrap.
+func uploadEtcdSnapshot(ctx context.Context, client machine.MachineServiceClient, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stream, err := client.EtcdRecover(ctx)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&common.Data{Bytes: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	for _, msg := range resp.Messages {
+		if msg.Metadata != nil && msg.Metadata.Error != "" {
+			return errors.New(msg.Metadata.Error)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccBootstrapResource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccBootstrapResourceConfig(talos),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_bootstrap.test", "cluster_ca_certificate", "cluster-ca"),
+					resource.TestCheckResourceAttr("talos_bootstrap.test", "client_certificate", "client-crt"),
+					resource.TestCheckResourceAttr("talos_bootstrap.test", "client_key", "client-key"),
+					resource.TestCheckResourceAttrSet("talos_bootstrap.test", "raw"),
+					testCheckCalls(talos, "Bootstrap", 1),
+				),
+			},
+		},
+	})
+}
+
+func TestAccBootstrapResource_error(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("Bootstrap", status.Error(codes.FailedPrecondition, "etcd data directory is not empty"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccBootstrapResourceConfig(talos),
+				ExpectError: regexp.MustCompile("etcd data directory is not empty"),
+			},
+		},
+	})
+}
+
+func testAccBootstrapResourceConfig(talos *fakeTalos) string {
+	return fmt.Sprintf(`
+resource "talos_bootstrap" "test" {%s}
+`, talos.testClientConfig())
+}
+
+// testCheckCalls checks that method of the fake Talos API was called n times.
+func testCheckCalls(talos *fakeTalos, method string, n int) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		if calls := talos.Calls(method); calls != n {
+			return fmt.Errorf("%s called %d times, expected %d", method, calls, n)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,397 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/talos-systems/talos/pkg/machinery/api/common"
+	tc "github.com/talos-systems/talos/pkg/machinery/client"
+	"github.com/talos-systems/talos/pkg/machinery/constants"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// endpointValidator checks that endpoints can be parsed at plan time.
+var endpointValidator = attribute_validator.StringFunc("a Talos API endpoint", func(endpoint string) error {
+	_, _, err := parseEndpoint(endpoint)
+	return err
+})
+
+// clientAttributes are the attributes needed to connect to the Talos API of a
+// node.
+var clientAttributes = map[string]tfsdk.Attribute{
+	"endpoint": {
+		MarkdownDescription: "Address of Talos node handling the request, as `host`, `host:port`, `[ipv6]:port` or `https://host:port` (the port defaults to the apid port).",
+		Required:            true,
+		Type:                types.StringType,
+		Validators: []tfsdk.AttributeValidator{
+			endpointValidator,
+		},
+	},
+	"endpoints": {
+		MarkdownDescription: "Fallback addresses of Talos nodes, tried in order when `endpoint` cannot be reached.",
+		Optional:            true,
+		Type: types.ListType{
+			ElemType: types.StringType,
+		},
+		Validators: []tfsdk.AttributeValidator{
+			endpointValidator,
+		},
+	},
+	"machine_ca": {
+		MarkdownDescription: "PEM-encoded root certificates bundle for TLS authentication.",
+		Required:            true,
+		PlanModifiers: []tfsdk.AttributePlanModifier{
+			attribute_plan_modifier.NormalizePEM(),
+		},
+		Type: types.StringType,
+		Validators: []tfsdk.AttributeValidator{
+			attribute_validator.PEMCertificate(),
+		},
+	},
+	"machine_crt": {
+		MarkdownDescription: "PEM-encoded client certificate for TLS authentication.",
+		Required:            true,
+		PlanModifiers: []tfsdk.AttributePlanModifier{
+			attribute_plan_modifier.NormalizePEM(),
+		},
+		Type: types.StringType,
+		Validators: []tfsdk.AttributeValidator{
+			attribute_validator.PEMCertificate(),
+		},
+	},
+	"machine_key": {
+		MarkdownDescription: "PEM-encoded client certificate key for TLS authentication.",
+		Required:            true,
+		PlanModifiers: []tfsdk.AttributePlanModifier{
+			attribute_plan_modifier.NormalizePEM(),
+		},
+		Type: types.StringType,
+		Validators: []tfsdk.AttributeValidator{
+			attribute_validator.PEMPrivateKey(),
+		},
+	},
+}
+
+// withClientAttributes returns a new attribute map containing both the
+// client attributes and the given ones.
+func withClientAttributes(attrs map[string]tfsdk.Attribute) map[string]tfsdk.Attribute {
+	result := make(map[string]tfsdk.Attribute, len(clientAttributes)+len(attrs))
+	for name, attr := range clientAttributes {
+		result[name] = attr
+	}
+	for name, attr := range attrs {
+		result[name] = attr
+	}
+	return result
+}
+
+// targetEndpointAttribute returns the endpoint attribute of a resource acting
+// on the node at endpoint unless nodeAttribute is set, which is replaced when
+// endpoint designates another node.
+func targetEndpointAttribute(nodeAttribute string) tfsdk.Attribute {
+	attribute := clientAttributes["endpoint"]
+	attribute.PlanModifiers = []tfsdk.AttributePlanModifier{
+		attribute_plan_modifier.RequiresReplaceIf(
+			fmt.Sprintf("if the endpoint designates another node and `%s` is not set", nodeAttribute),
+			func(ctx context.Context, req tfsdk.ModifyAttributePlanRequest) (bool, diag.Diagnostics) {
+				var node attr.Value
+				diags := req.Config.GetAttribute(ctx, path.Root(nodeAttribute), &node)
+				if diags.HasError() || !node.IsNull() {
+					return false, diags
+				}
+
+				state, plan := req.AttributeState.(types.String), req.AttributePlan.(types.String)
+				_, stateAddress, err := parseEndpoint(state.Value)
+				if err != nil {
+					return true, diags
+				}
+				_, planAddress, err := parseEndpoint(plan.Value)
+				if err != nil {
+					return true, diags
+				}
+				return stateAddress != planAddress, diags
+			},
+		),
+	}
+	return attribute
+}
+
+const (
+	// defaultDialTimeout bounds the time spent waiting for the Talos API to
+	// be reachable, so that the last connection error is reported rather
+	// than waiting forever.
+	defaultDialTimeout = time.Minute
+	// dialTimeoutEnv is the environment variable overriding
+	// defaultDialTimeout, for nodes that take longer to boot.
+	dialTimeoutEnv = "TALOS_DIAL_TIMEOUT"
+)
+
+// dialTimeout returns the time to wait for the Talos API to be reachable.
+func dialTimeout() (time.Duration, error) {
+	value, ok := os.LookupEnv(dialTimeoutEnv)
+	if !ok || value == "" {
+		return defaultDialTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", dialTimeoutEnv, err)
+	}
+	return timeout, nil
+}
+
+// dialTalos opens a mutual TLS gRPC connection to the Talos API of the node
+// at endpoint, or at the first of endpoints that can be reached, blocking
+// until the connection is up or the dial timeout expires. The dial stops
+// early when the certificate of a node cannot be verified. The options are
+// applied after the default ones.
+func dialTalos(ctx context.Context, endpoint types.String, endpoints types.List, machineCa, machineCrt, machineKey types.String, opts ...grpc.DialOption) (*grpc.ClientConn, diag.Diagnostics) {
+	addresses, diags := clientEndpoints(ctx, endpoint, endpoints)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	// Report invalid credentials now rather than after the dial timeout
+	diags.Append(checkClientCredentials(machineCa.Value, machineCrt.Value, machineKey.Value, nil, time.Now())...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	tlsCredentials, credDiags := clientCredentials(machineCa, machineCrt, machineKey)
+	diags.Append(credDiags...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	timeout, err := dialTimeout()
+	if err != nil {
+		diags.AddError(
+			"Error creating gRPC connection",
+			err.Error(),
+		)
+		return nil, diags
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	failFast := &certificateFailFast{TransportCredentials: tlsCredentials, cancel: cancel}
+
+	conn, err := dial(dialCtx, addresses, failFast, append([]grpc.DialOption{grpc.WithBlock(), grpc.WithReturnConnectionError()}, opts...)...)
+	if err != nil {
+		if certErr := failFast.Err(); certErr != nil {
+			err = certErr
+		}
+		if classifyTalosError(err) == talosErrorCertificateValidity {
+			err = fmt.Errorf("%w\n\n%s", err, clockSkewDetail(ctx, addresses, machineCa, machineCrt, machineKey, opts...))
+		}
+		addTalosError(&diags, err, "Error creating gRPC connection", err.Error)
+		return nil, diags
+	}
+
+	return conn, diags
+}
+
+// clientEndpoints returns endpoint followed by the fallback endpoints.
+func clientEndpoints(ctx context.Context, endpoint types.String, endpoints types.List) ([]string, diag.Diagnostics) {
+	var fallbacks []string
+	diags := endpoints.ElementsAs(ctx, &fallbacks, false)
+
+	return append([]string{endpoint.Value}, fallbacks...), diags
+}
+
+// dialTalosMaintenance opens a gRPC connection to the maintenance API of the
+// first of endpoints that can be reached, a node that has not been configured
+// yet. The maintenance API does not authenticate clients, and the node
+// certificate cannot be verified.
+func dialTalosMaintenance(ctx context.Context, endpoints []string) (*grpc.ClientConn, error) {
+	return dial(ctx, endpoints, credentials.NewTLS(&tls.Config{
+		InsecureSkipVerify: true,
+	}))
+}
+
+// clientCredentials returns the mutual TLS credentials to authenticate to the
+// Talos API.
+func clientCredentials(machineCa, machineCrt, machineKey types.String) (credentials.TransportCredentials, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	clientCert, err := tls.X509KeyPair(
+		[]byte(attribute_plan_modifier.TrimPEM(machineCrt.Value)),
+		[]byte(attribute_plan_modifier.TrimPEM(machineKey.Value)),
+	)
+	if err != nil {
+		diags.AddError(
+			"Error parsing key pair",
+			err.Error(),
+		)
+		return nil, diags
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM([]byte(attribute_plan_modifier.TrimPEM(machineCa.Value))) {
+		diags.AddError(
+			"failed to add server CA's certificate",
+			"",
+		)
+		return nil, diags
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      certPool,
+	}), diags
+}
+
+// dial opens a gRPC connection to the first of endpoints that can be
+// reached, through the proxy set in the environment if any. The endpoints are
+// tried in order by the pick_first balancer, which also fails over to the
+// next one when the connection is lost.
+func dial(ctx context.Context, endpoints []string, creds credentials.TransportCredentials, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	addresses := make([]resolver.Address, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		host, address, err := parseEndpoint(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+		}
+		// The server name overrides the authority of the connection, so
+		// that the certificate of each endpoint is verified against its
+		// own host.
+		addresses = append(addresses, resolver.Address{Addr: address, ServerName: host})
+	}
+
+	proxy, err := withProxy("")
+	if err != nil {
+		return nil, err
+	}
+
+	r := manual.NewBuilderWithScheme("talos")
+	r.InitialState(resolver.State{Addresses: addresses})
+
+	return grpc.DialContext(
+		ctx,
+		fmt.Sprintf("%s:///%s", r.Scheme(), addresses[0].Addr),
+		append([]grpc.DialOption{grpc.WithResolvers(r), grpc.WithTransportCredentials(creds), proxy}, opts...)...,
+	)
+}
+
+// parseEndpoint parses an endpoint given as `host`, `host:port`,
+// `[ipv6]:port`, a bare IPv6 address or `https://host:port`, and returns its
+// host and its address, using the apid port by default.
+func parseEndpoint(endpoint string) (string, string, error) {
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return "", "", err
+		}
+		if u.Scheme != "https" {
+			return "", "", fmt.Errorf("unsupported scheme %q, must be https", u.Scheme)
+		}
+		if u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return "", "", errors.New("URL must only have a host and a port")
+		}
+		endpoint = u.Host
+	}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		// There is no port, the address may be a bare or bracketed IPv6
+		// address.
+		host = strings.TrimSuffix(strings.TrimPrefix(endpoint, "["), "]")
+		port = strconv.Itoa(constants.ApidPort)
+	}
+
+	if host == "" {
+		return "", "", errors.New("missing host")
+	}
+	if strings.ContainsAny(host, ":[]") && net.ParseIP(host) == nil {
+		return "", "", fmt.Errorf("invalid host %q", host)
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return "", "", fmt.Errorf("invalid port %q", port)
+	}
+
+	return host, net.JoinHostPort(host, port), nil
+}
+
+// nodeContext returns a context that makes the endpoint proxy requests to
+// node, or ctx itself if node is empty.
+func nodeContext(ctx context.Context, node string) context.Context {
+	if node == "" {
+		return ctx
+	}
+	return tc.WithNode(ctx, node)
+}
+
+// nodesContext returns a context that makes the endpoint fan requests out to
+// nodes and aggregate the responses, or ctx itself if nodes only contains the
+// endpoint, denoted by an empty node.
+func nodesContext(ctx context.Context, nodes []string) context.Context {
+	if len(nodes) == 0 || (len(nodes) == 1 && nodes[0] == "") {
+		return ctx
+	}
+	return tc.WithNodes(ctx, nodes...)
+}
+
+// nodeMessage is a message of a response aggregated by apid, carrying the
+// node it comes from.
+type nodeMessage interface {
+	GetMetadata() *common.Metadata
+}
+
+// splitNodeMessages maps the messages of a response aggregated from nodes to
+// the node they come from. The errors reported by nodes, or the missing
+// responses, are returned separately.
+func splitNodeMessages[T nodeMessage](nodes []string, messages []T) (map[string]T, map[string]error) {
+	results := map[string]T{}
+	errs := map[string]error{}
+
+	for _, msg := range messages {
+		node := msg.GetMetadata().GetHostname()
+		if node == "" && len(nodes) == 1 {
+			node = nodes[0]
+		}
+		if e := msg.GetMetadata().GetError(); e != "" {
+			errs[node] = errors.New(e)
+			continue
+		}
+		results[node] = msg
+	}
+
+	for _, node := range nodes {
+		if _, ok := results[node]; ok {
+			continue
+		}
+		if _, ok := errs[node]; !ok {
+			errs[node] = errors.New("no response from node")
+		}
+	}
+
+	return results, errs
+}
+
+// nodeErrors returns err for each of nodes, when a request fanned out to them
+// failed as a whole.
+func nodeErrors(nodes []string, err error) map[string]error {
+	errs := make(map[string]error, len(nodes))
+	for _, node := range nodes {
+		errs[node] = err
+	}
+	return errs
+}
@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/talos-systems/talos/pkg/machinery/role"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+)
+
+var (
+	// adminRoles are the roles allowed to change the state of nodes.
+	adminRoles = []role.Role{role.Admin}
+	// readerRoles are the roles allowed to read the state of nodes.
+	readerRoles = []role.Role{role.Admin, role.Reader}
+	// etcdBackupRoles are the roles allowed to take etcd snapshots.
+	etcdBackupRoles = []role.Role{role.Admin, role.EtcdBackup}
+)
+
+// clientCredentialsValidator checks the client attributes of a resource or a
+// data source, so that invalid credentials are reported before connecting.
+type clientCredentialsValidator struct {
+	roles []role.Role
+}
+
+var (
+	_ resource.ConfigValidator   = clientCredentialsValidator{}
+	_ datasource.ConfigValidator = clientCredentialsValidator{}
+)
+
+func (v clientCredentialsValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v clientCredentialsValidator) MarkdownDescription(ctx context.Context) string {
+	roles := make([]string, len(v.roles))
+	for i, r := range v.roles {
+		roles[i] = string(r)
+	}
+	return "`machine_crt` must match `machine_key`, be valid, be signed by `machine_ca`, allow client authentication, and should have one of the roles " +
+		strings.Join(roles, ", ")
+}
+
+func (v clientCredentialsValidator) ValidateResource(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+func (v clientCredentialsValidator) ValidateDataSource(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+func (v clientCredentialsValidator) validate(ctx context.Context, config tfsdk.Config) diag.Diagnostics {
+	var machineCa, machineCrt, machineKey types.String
+	diags := config.GetAttribute(ctx, path.Root("machine_ca"), &machineCa)
+	diags.Append(config.GetAttribute(ctx, path.Root("machine_crt"), &machineCrt)...)
+	diags.Append(config.GetAttribute(ctx, path.Root("machine_key"), &machineKey)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	// Unknown values are checked once they are known, when connecting
+	if machineCrt.Null || machineCrt.Unknown {
+		return diags
+	}
+
+	var ca, key string
+	if !machineCa.Null && !machineCa.Unknown {
+		ca = machineCa.Value
+	}
+	if !machineKey.Null && !machineKey.Unknown {
+		key = machineKey.Value
+	}
+
+	diags.Append(checkClientCredentials(ca, machineCrt.Value, key, v.roles, time.Now())...)
+	return diags
+}
+
+// checkClientCredentials checks that the client certificate crt matches key,
+// is valid at now, is signed by ca and allows client authentication, and warns
+// if it has none of roles. Empty arguments skip the related checks, as do values that cannot
+// be parsed, which are reported by the attribute validators.
+func checkClientCredentials(ca, crt, key string, roles []role.Role, now time.Time) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	ca = attribute_plan_modifier.TrimPEM(ca)
+	crt = attribute_plan_modifier.TrimPEM(crt)
+	key = attribute_plan_modifier.TrimPEM(key)
+
+	certs := parseCertificates(crt)
+	if len(certs) == 0 {
+		return diags
+	}
+	cert := certs[0]
+
+	if publicKey, ok := parsePrivateKeyPublic(key); ok {
+		if certKey, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !certKey.Equal(publicKey) {
+			diags.AddAttributeError(
+				path.Root("machine_key"),
+				"Client key does not match certificate",
+				"The private key in machine_key is not the key of the certificate in machine_crt. Check that both come from the same talosconfig.",
+			)
+		}
+	}
+
+	if now.Before(cert.NotBefore) {
+		diags.AddAttributeError(
+			path.Root("machine_crt"),
+			"Client certificate is not valid yet",
+			fmt.Sprintf("The certificate in machine_crt is valid from %s, check the clock of this machine.", cert.NotBefore.Format(time.RFC3339)),
+		)
+	}
+	if now.After(cert.NotAfter) {
+		diags.AddAttributeError(
+			path.Root("machine_crt"),
+			"Client certificate has expired",
+			fmt.Sprintf("The certificate in machine_crt expired at %s, generate a new one with `talosctl config new`.", cert.NotAfter.Format(time.RFC3339)),
+		)
+	}
+
+	if cas := parseCertificates(ca); len(cas) > 0 {
+		opts := x509.VerifyOptions{
+			Roots:         x509.NewCertPool(),
+			Intermediates: x509.NewCertPool(),
+			// The validity period is checked above
+			CurrentTime: cert.NotBefore,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+		for _, c := range cas {
+			opts.Roots.AddCert(c)
+		}
+		for _, c := range certs[1:] {
+			opts.Intermediates.AddCert(c)
+		}
+		if _, err := cert.Verify(opts); err != nil {
+			diags.AddAttributeError(
+				path.Root("machine_crt"),
+				"Client certificate is not signed by the CA",
+				fmt.Sprintf("The certificate in machine_crt does not chain to machine_ca: %s. Check that both come from the same talosconfig.", err),
+			)
+		}
+	}
+
+	// Certificates without extended key usages are valid for any usage
+	if len(cert.ExtKeyUsage) > 0 && !hasClientAuth(cert) {
+		diags.AddAttributeError(
+			path.Root("machine_crt"),
+			"Client certificate does not allow client authentication",
+			"The certificate in machine_crt lacks the client authentication extended key usage, it is probably a server certificate.",
+		)
+	}
+
+	if len(roles) > 0 {
+		certRoles, _ := role.Parse(cert.Subject.Organization)
+		if !certRoles.IncludesAny(role.MakeSet(roles...)) {
+			allowed := make([]string, len(roles))
+			for i, r := range roles {
+				allowed[i] = string(r)
+			}
+			current := "no role"
+			if s := certRoles.Strings(); len(s) > 0 {
+				current = "the roles " + strings.Join(s, ", ")
+			}
+			// Only a warning, as the node has the final word on the roles
+			diags.AddAttributeWarning(
+				path.Root("machine_crt"),
+				"Client certificate role not allowed",
+				fmt.Sprintf("The certificate in machine_crt has %s, but one of %s is required, so the node will probably deny the requests. Generate a certificate with `talosctl config new --roles %s`.",
+					current, strings.Join(allowed, ", "), allowed[0]),
+			)
+		}
+	}
+
+	return diags
+}
+
+// parseCertificates parses the PEM-encoded certificates of a bundle, stopping
+// at the first invalid one.
+func parseCertificates(bundle string) []*x509.Certificate {
+	var certs []*x509.Certificate
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return certs
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return certs
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// parsePrivateKeyPublic returns the public key of a PEM-encoded private key.
+func parsePrivateKeyPublic(key string) (crypto.PublicKey, bool) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, false
+	}
+
+	var parsed interface{}
+	var err error
+	if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			if parsed, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+				return nil, false
+			}
+		}
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, false
+	}
+	return signer.Public(), true
+}
+
+// hasClientAuth tells whether a certificate allows client authentication.
+func hasClientAuth(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageClientAuth || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/talos-systems/talos/pkg/machinery/role"
+)
+
+func TestCheckClientCredentials(t *testing.T) {
+	newCA := func() (*x509.Certificate, *ecdsa.PrivateKey) {
+		return testCertificate(t, nil, nil, func(template *x509.Certificate) {
+			template.Subject = pkix.Name{CommonName: "talos"}
+			template.IsCA = true
+			template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+			template.BasicConstraintsValid = true
+		})
+	}
+	ca, caKey := newCA()
+	otherCA, _ := newCA()
+
+	newClient := func(organization string, usage x509.ExtKeyUsage) (string, string) {
+		crt, key := testCertificate(t, ca, caKey, func(template *x509.Certificate) {
+			template.Subject = pkix.Name{Organization: []string{organization}}
+			template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		})
+		return string(encodeCertificate(crt)), string(encodeKey(t, key))
+	}
+	adminCrt, adminKey := newClient(string(role.Admin), x509.ExtKeyUsageClientAuth)
+	readerCrt, readerKey := newClient(string(role.Reader), x509.ExtKeyUsageClientAuth)
+	serverCrt, serverKey := newClient(string(role.Admin), x509.ExtKeyUsageServerAuth)
+
+	now := time.Now()
+	for name, tt := range map[string]struct {
+		ca, crt, key string
+		roles        []role.Role
+		now          time.Time
+		summaries    []string
+	}{
+		"valid": {
+			ca: string(encodeCertificate(ca)), crt: adminCrt, key: adminKey, roles: adminRoles, now: now,
+		},
+		"reader": {
+			ca: string(encodeCertificate(ca)), crt: readerCrt, key: readerKey, roles: readerRoles, now: now,
+		},
+		"unknown values": {
+			crt: adminCrt, roles: adminRoles, now: now,
+		},
+		"mismatched key": {
+			ca: string(encodeCertificate(ca)), crt: adminCrt, key: readerKey, roles: adminRoles, now: now,
+			summaries: []string{"Client key does not match certificate"},
+		},
+		"expired": {
+			ca: string(encodeCertificate(ca)), crt: adminCrt, key: adminKey, roles: adminRoles, now: now.Add(2 * time.Hour),
+			summaries: []string{"Client certificate has expired"},
+		},
+		"not valid yet": {
+			ca: string(encodeCertificate(ca)), crt: adminCrt, key: adminKey, roles: adminRoles, now: now.Add(-2 * time.Hour),
+			summaries: []string{"Client certificate is not valid yet"},
+		},
+		"other CA": {
+			ca: string(encodeCertificate(otherCA)), crt: adminCrt, key: adminKey, roles: adminRoles, now: now,
+			summaries: []string{"Client certificate is not signed by the CA"},
+		},
+		"server certificate": {
+			ca: string(encodeCertificate(ca)), crt: serverCrt, key: serverKey, roles: adminRoles, now: now,
+			summaries: []string{"Client certificate does not allow client authentication"},
+		},
+		"role too weak": {
+			ca: string(encodeCertificate(ca)), crt: readerCrt, key: readerKey, roles: adminRoles, now: now,
+			summaries: []string{"Client certificate role not allowed"},
+		},
+		"several failures": {
+			ca: string(encodeCertificate(otherCA)), crt: readerCrt, key: adminKey, roles: adminRoles, now: now,
+			summaries: []string{
+				"Client key does not match certificate",
+				"Client certificate is not signed by the CA",
+				"Client certificate role not allowed",
+			},
+		},
+	} {
+		diags := checkClientCredentials(tt.ca, tt.crt, tt.key, tt.roles, tt.now)
+		if len(diags) != len(tt.summaries) {
+			t.Errorf("%s: expected %d diagnostics, got %v", name, len(tt.summaries), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Summary() != tt.summaries[i] {
+				t.Errorf("%s: expected %q, got %q", name, tt.summaries[i], d.Summary())
+			}
+			// Role mismatches are left to the node to reject
+			expected := diag.SeverityError
+			if d.Summary() == "Client certificate role not allowed" {
+				expected = diag.SeverityWarning
+			}
+			if d.Severity() != expected {
+				t.Errorf("%s: expected %q to have severity %s, got %s", name, d.Summary(), expected, d.Severity())
+			}
+		}
+	}
+}
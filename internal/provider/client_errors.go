@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	timeapi "github.com/talos-systems/talos/pkg/machinery/api/time"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// clockCheckTimeout bounds the time spent reading the clock of a node
+	// whose certificate is not valid at the local time.
+	clockCheckTimeout = 10 * time.Second
+	// clockSkewTolerance is the clock skew below which the clocks of the
+	// node and of this machine are considered to agree.
+	clockSkewTolerance = time.Minute
+)
+
+// talosErrorClass is a common failure of the requests to the Talos API.
+type talosErrorClass int
+
+const (
+	talosErrorUnknown talosErrorClass = iota
+	talosErrorUnknownAuthority
+	talosErrorCertificateValidity
+	talosErrorCertificateRejected
+	talosErrorPermissionDenied
+	talosErrorConnectionRefused
+	talosErrorUnimplemented
+)
+
+// talosErrorHints are the summaries and remediation hints of the common
+// failures.
+var talosErrorHints = map[talosErrorClass]struct{ summary, hint string }{
+	talosErrorUnknownAuthority: {
+		"Talos API certificate signed by unknown authority",
+		"The certificate of the node is not signed by machine_ca. Check that machine_ca comes from the talosconfig of this cluster: " +
+			"if the node was reinstalled with new secrets, the client credentials must be generated again from them.",
+	},
+	talosErrorCertificateValidity: {
+		"Talos API certificate not valid yet or expired",
+		"The certificate of the node is not valid at the time of this machine, which usually means that the clocks of the node and of this machine disagree. " +
+			"Check that both synchronize their time, the node through its time servers.",
+	},
+	talosErrorCertificateRejected: {
+		"Client certificate rejected by the Talos API",
+		"The node did not accept machine_crt. Check that it comes from the talosconfig of this cluster, " +
+			"and that the clock of the node is correct, as it checks the validity period of the certificate.",
+	},
+	talosErrorPermissionDenied: {
+		"Permission denied by the Talos API",
+		"The role of machine_crt does not allow this request. Generate a certificate with the os:admin role with `talosctl config new --roles os:admin`.",
+	},
+	talosErrorConnectionRefused: {
+		"Connection to the Talos API refused",
+		"Nothing listens on the endpoint: the node may not be booted yet, or may still be in maintenance mode waiting for its configuration. " +
+			"Check the endpoint, and that the configuration was applied to the node.",
+	},
+	talosErrorUnimplemented: {
+		"Request not supported by the Talos API",
+		"The node runs a Talos version that does not implement this request, upgrade it to a newer version.",
+	},
+}
+
+// classifyTalosError returns the class of a failed request to the Talos API.
+// Connection errors are only available as text once they went through gRPC,
+// so they are matched on their message.
+func classifyTalosError(err error) talosErrorClass {
+	// Stream errors may be wrapped
+	var statusErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &statusErr) {
+		switch statusErr.GRPCStatus().Code() {
+		case codes.PermissionDenied:
+			return talosErrorPermissionDenied
+		case codes.Unimplemented:
+			return talosErrorUnimplemented
+		}
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	message := err.Error()
+	switch {
+	case errors.As(err, &unknownAuthority), strings.Contains(message, "certificate signed by unknown authority"):
+		return talosErrorUnknownAuthority
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired, strings.Contains(message, "certificate has expired or is not yet valid"):
+		return talosErrorCertificateValidity
+	case strings.Contains(message, "tls: bad certificate"), strings.Contains(message, "tls: expired certificate"), strings.Contains(message, "tls: unknown certificate authority"):
+		return talosErrorCertificateRejected
+	case strings.Contains(message, "connection refused"):
+		return talosErrorConnectionRefused
+	}
+	return talosErrorUnknown
+}
+
+// certificateFailFast wraps the transport credentials of a blocking dial to
+// stop it at the first certificate verification failure, which waiting does
+// not fix, unlike a node that is still booting.
+type certificateFailFast struct {
+	credentials.TransportCredentials
+
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *certificateFailFast) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+	if err != nil {
+		switch classifyTalosError(err) {
+		case talosErrorUnknownAuthority, talosErrorCertificateValidity:
+			c.mu.Lock()
+			if c.err == nil {
+				c.err = err
+			}
+			c.mu.Unlock()
+			c.cancel()
+		}
+	}
+	return conn, info, err
+}
+
+// Err returns the certificate verification failure that stopped the dial, if
+// any.
+func (c *certificateFailFast) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// addTalosError adds the diagnostic of a failed request to the Talos API to
+// diags. The detail is only computed for the failures that are not common
+// ones, as it may query the node.
+func addTalosError(diags *diag.Diagnostics, err error, summary string, detail func() string) {
+	if hint, ok := talosErrorHints[classifyTalosError(err)]; ok {
+		diags.AddError(hint.summary, fmt.Sprintf("%s\n\n%s", hint.hint, err))
+		return
+	}
+	diags.AddError(summary, detail())
+}
+
+// clockSkewDetail reads the clock of the node to explain why its certificate
+// is not valid at the local time.
+func clockSkewDetail(ctx context.Context, addresses []string, machineCa, machineCrt, machineKey types.String, opts ...grpc.DialOption) string {
+	clientCert, err := tls.X509KeyPair(
+		[]byte(attribute_plan_modifier.TrimPEM(machineCrt.Value)),
+		[]byte(attribute_plan_modifier.TrimPEM(machineKey.Value)),
+	)
+	if err != nil {
+		return fmt.Sprintf("Could not read the clock of the node: %s", err)
+	}
+	ca := x509.NewCertPool()
+	ca.AppendCertsFromPEM([]byte(attribute_plan_modifier.TrimPEM(machineCa.Value)))
+
+	skew, err := nodeClockSkew(ctx, addresses, ca, clientCert, opts...)
+	if err != nil {
+		return fmt.Sprintf("Could not read the clock of the node: %s", err)
+	}
+	return describeClockSkew(skew)
+}
+
+// nodeClockSkew returns how far the clock of the node at the first of
+// addresses is ahead of the local clock, read through the time API. The
+// certificate of the node is verified against ca regardless of its validity
+// period, which is the failure being explained.
+func nodeClockSkew(ctx context.Context, addresses []string, ca *x509.CertPool, clientCert tls.Certificate, opts ...grpc.DialOption) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, clockCheckTimeout)
+	defer cancel()
+
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		// The chain is verified below, ignoring the validity period
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("no certificate presented by the node")
+			}
+			leaf := cs.PeerCertificates[0]
+			verifyOpts := x509.VerifyOptions{
+				Roots:         ca,
+				Intermediates: x509.NewCertPool(),
+				DNSName:       cs.ServerName,
+				CurrentTime:   leaf.NotBefore,
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				verifyOpts.Intermediates.AddCert(cert)
+			}
+			_, err := leaf.Verify(verifyOpts)
+			return err
+		},
+	})
+
+	conn, err := dial(ctx, addresses, creds, append([]grpc.DialOption{grpc.WithBlock(), grpc.WithReturnConnectionError()}, opts...)...)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	resp, err := timeapi.NewTimeServiceClient(conn).Time(ctx, &emptypb.Empty{})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Messages) == 0 || resp.Messages[0].Localtime == nil {
+		return 0, errors.New("no time in the response of the node")
+	}
+	// Compare with the local time halfway through the request
+	local := start.Add(time.Since(start) / 2)
+
+	return resp.Messages[0].Localtime.AsTime().Sub(local), nil
+}
+
+// describeClockSkew explains a clock skew between the node and this machine.
+func describeClockSkew(skew time.Duration) string {
+	switch {
+	case skew > clockSkewTolerance:
+		return fmt.Sprintf("The clock of the node is %s ahead of the clock of this machine.", skew.Round(time.Second))
+	case skew < -clockSkewTolerance:
+		return fmt.Sprintf("The clock of the node is %s behind the clock of this machine.", (-skew).Round(time.Second))
+	}
+	return "The clocks of the node and of this machine agree, so the certificate of the node is really not valid at this time: " +
+		"check the validity period of the certificates issued by machine_ca."
+}
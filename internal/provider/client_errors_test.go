@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyTalosError(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	// dialError returns the error of a connection to endpoint trusting the
+	// certificates of ca.
+	dialError := func(endpoint, ca string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		clientCert, err := tls.X509KeyPair([]byte(talos.Crt), []byte(talos.Key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM([]byte(ca))
+
+		conn, err := dial(ctx, []string{endpoint}, credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{clientCert},
+			RootCAs:      roots,
+		}), grpc.WithBlock(), grpc.WithReturnConnectionError())
+		if err == nil {
+			conn.Close()
+			t.Fatalf("connection to %s should fail", endpoint)
+		}
+		return err
+	}
+
+	otherCA, _ := testCertificate(t, nil, nil, func(template *x509.Certificate) {
+		template.Subject = pkix.Name{CommonName: "talos"}
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		template.BasicConstraintsValid = true
+	})
+
+	for name, tt := range map[string]struct {
+		err      error
+		expected talosErrorClass
+	}{
+		"permission denied":  {status.Error(codes.PermissionDenied, "not authorized"), talosErrorPermissionDenied},
+		"unimplemented":      {status.Error(codes.Unimplemented, "unknown method Kubeconfig"), talosErrorUnimplemented},
+		"wrapped":            {fmt.Errorf("reading stream: %w", status.Error(codes.PermissionDenied, "not authorized")), talosErrorPermissionDenied},
+		"other status":       {status.Error(codes.NotFound, "kubeconfig not found"), talosErrorUnknown},
+		"unknown authority":  {dialError(talos.Endpoint, string(encodeCertificate(otherCA))), talosErrorUnknownAuthority},
+		"connection refused": {dialError("127.0.0.1:1", talos.CA), talosErrorConnectionRefused},
+		"expired": {
+			x509.CertificateInvalidError{Reason: x509.Expired, Detail: "current time is after NotAfter"},
+			talosErrorCertificateValidity,
+		},
+		"certificate rejected": {
+			status.Error(codes.Unavailable, "connection closed before server preface received: remote error: tls: bad certificate"),
+			talosErrorCertificateRejected,
+		},
+	} {
+		if class := classifyTalosError(tt.err); class != tt.expected {
+			t.Errorf("%s: expected class %d, got %d for %q", name, tt.expected, class, tt.err)
+		}
+	}
+}
+
+func TestNodeClockSkew(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetClockSkew(-time.Hour)
+
+	clientCert, err := tls.X509KeyPair([]byte(talos.Crt), []byte(talos.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := x509.NewCertPool()
+	ca.AppendCertsFromPEM([]byte(talos.CA))
+
+	skew, err := nodeClockSkew(context.Background(), []string{talos.Endpoint}, ca, clientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skew > -time.Hour+clockSkewTolerance || skew < -time.Hour-clockSkewTolerance {
+		t.Errorf("expected a skew of about -1h, got %s", skew)
+	}
+	if detail := describeClockSkew(skew); detail != "The clock of the node is 1h0m0s behind the clock of this machine." {
+		t.Errorf("unexpected description %q", detail)
+	}
+}
+
+func TestDialTalosCertificateFailFast(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	// Client credentials from another cluster, valid on their own
+	otherCA, otherCAKey := testCertificate(t, nil, nil, func(template *x509.Certificate) {
+		template.Subject = pkix.Name{CommonName: "talos"}
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		template.BasicConstraintsValid = true
+	})
+	clientCrt, clientKey := testCertificate(t, otherCA, otherCAKey, func(template *x509.Certificate) {
+		template.Subject = pkix.Name{Organization: []string{"os:admin"}}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, diags := dialTalos(
+		ctx,
+		types.String{Value: talos.Endpoint},
+		types.List{ElemType: types.StringType, Null: true},
+		types.String{Value: string(encodeCertificate(otherCA))},
+		types.String{Value: string(encodeCertificate(clientCrt))},
+		types.String{Value: string(encodeKey(t, clientKey))},
+	)
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("the dial should stop at the certificate error, took %s", time.Since(start))
+	}
+	if len(diags) != 1 || diags[0].Summary() != talosErrorHints[talosErrorUnknownAuthority].summary {
+		t.Errorf("expected an unknown authority error, got %v", diags)
+	}
+}
+
+func TestDialTalosConnectionRefused(t *testing.T) {
+	talos := newFakeTalos(t)
+	t.Setenv(dialTimeoutEnv, "2s")
+
+	start := time.Now()
+	_, diags := dialTalos(
+		context.Background(),
+		types.String{Value: "127.0.0.1:1"},
+		types.List{ElemType: types.StringType, Null: true},
+		types.String{Value: talos.CA},
+		types.String{Value: talos.Crt},
+		types.String{Value: talos.Key},
+	)
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("the dial should stop after %s, took %s", dialTimeoutEnv, time.Since(start))
+	}
+	if len(diags) != 1 || diags[0].Summary() != talosErrorHints[talosErrorConnectionRefused].summary {
+		t.Errorf("expected a connection refused error, got %v", diags)
+	}
+}
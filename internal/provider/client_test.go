@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for endpoint, expected := range map[string]string{
+		"10.5.0.2":                    "10.5.0.2:50000",
+		"10.5.0.2:8443":               "10.5.0.2:8443",
+		"fd00::2":                     "[fd00::2]:50000",
+		"[fd00::2]":                   "[fd00::2]:50000",
+		"[fd00::2]:8443":              "[fd00::2]:8443",
+		"cp.example.com":              "cp.example.com:50000",
+		"https://cp.example.com:8443": "cp.example.com:8443",
+		"https://[fd00::2]":           "[fd00::2]:50000",
+	} {
+		_, address, err := parseEndpoint(endpoint)
+		if err != nil {
+			t.Errorf("parseEndpoint(%q): %s", endpoint, err)
+		} else if address != expected {
+			t.Errorf("parseEndpoint(%q) = %q, expected %q", endpoint, address, expected)
+		}
+	}
+
+	for _, endpoint := range []string{"", "http://cp.example.com", "cp.example.com:0", "cp.example.com:", "fd00::2::x", "https://cp.example.com/path"} {
+		if _, _, err := parseEndpoint(endpoint); err == nil {
+			t.Errorf("parseEndpoint(%q) should fail", endpoint)
+		}
+	}
+}
+
+func TestDialTalosFailover(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, diags := dialTalos(
+		ctx,
+		types.String{Value: "127.0.0.1:1"},
+		types.List{ElemType: types.StringType, Elems: []attr.Value{types.String{Value: talos.Endpoint}}},
+		types.String{Value: talos.CA},
+		types.String{Value: talos.Crt},
+		types.String{Value: talos.Key},
+	)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	defer conn.Close()
+
+	if _, err := machine.NewMachineServiceClient(conn).Version(ctx, &emptypb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplitNodeMessages(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Unreachable("10.5.0.3")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, diags := dialTalos(ctx, types.String{Value: talos.Endpoint}, types.List{ElemType: types.StringType, Null: true}, types.String{Value: talos.CA}, types.String{Value: talos.Crt}, types.String{Value: talos.Key})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	defer conn.Close()
+
+	nodes := []string{"10.5.0.2", "10.5.0.3", "10.5.0.4"}
+	resp, err := machine.NewMachineServiceClient(conn).Version(nodesContext(ctx, nodes), &emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, errs := splitNodeMessages(nodes, resp.Messages)
+	if len(versions) != 2 || versions["10.5.0.2"] == nil || versions["10.5.0.4"] == nil {
+		t.Errorf("unexpected versions %v", versions)
+	}
+	if len(errs) != 1 || errs["10.5.0.3"] == nil {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
@@ -0,0 +1,221 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/cluster"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var _ datasource.DataSource = &ClusterHealthDataSource{}
+
+func NewClusterHealthDataSource() datasource.DataSource {
+	return &ClusterHealthDataSource{}
+}
+
+type ClusterHealthDataSource struct{}
+
+type ClusterHealthDataSourceModel struct {
+	ID                 types.String              `tfsdk:"id"`
+	Endpoint           types.String              `tfsdk:"endpoint"`
+	Endpoints          types.List                `tfsdk:"endpoints"`
+	MachineCa          types.String              `tfsdk:"machine_ca"`
+	MachineCrt         types.String              `tfsdk:"machine_crt"`
+	MachineKey         types.String              `tfsdk:"machine_key"`
+	ControlPlaneNodes  types.List                `tfsdk:"control_plane_nodes"`
+	WorkerNodes        types.List                `tfsdk:"worker_nodes"`
+	KubernetesEndpoint types.String              `tfsdk:"kubernetes_endpoint"`
+	Timeout            types.String              `tfsdk:"timeout"`
+	Checks             []ClusterHealthCheckModel `tfsdk:"checks"`
+}
+
+type ClusterHealthCheckModel struct {
+	Name   types.String `tfsdk:"name"`
+	Status types.String `tfsdk:"status"`
+}
+
+// defaultHealthTimeout is the default time to wait for the cluster to become
+// healthy, as in `talosctl health`.
+const defaultHealthTimeout = "20m"
+
+func (d *ClusterHealthDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_cluster_health"
+}
+
+func (d *ClusterHealthDataSource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Wait for a Talos cluster to be healthy, running the same checks as `talosctl health`.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				Type:                types.StringType,
+			},
+			"control_plane_nodes": {
+				MarkdownDescription: "Control plane nodes expected in the cluster.",
+				Required:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"worker_nodes": {
+				MarkdownDescription: "Worker nodes expected in the cluster.",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"kubernetes_endpoint": {
+				MarkdownDescription: "Kubernetes API endpoint to use for the checks, instead of the one from the kubeconfig.",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"timeout": {
+				MarkdownDescription: fmt.Sprintf("Maximum time to wait for the cluster to become healthy (default \"%s\").", defaultHealthTimeout),
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"checks": {
+				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+					"name": {
+						Computed:            true,
+						MarkdownDescription: "Description of the check.",
+						Type:                types.StringType,
+					},
+					"status": {
+						Computed:            true,
+						MarkdownDescription: "Last status reported by the check.",
+						Type:                types.StringType,
+					},
+				}),
+				Computed:            true,
+				MarkdownDescription: "Results of the health checks.",
+			},
+		}),
+	}, nil
+}
+
+func (d *ClusterHealthDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		clientCredentialsValidator{readerRoles},
+	}
+}
+
+func (d *ClusterHealthDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data *ClusterHealthDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timeoutValue := data.Timeout.Value
+	if data.Timeout.Null {
+		timeoutValue = defaultHealthTimeout
+	}
+	timeout, err := time.ParseDuration(timeoutValue)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error parsing timeout",
+			err.Error(),
+		)
+		return
+	}
+
+	clusterInfo := &cluster.ClusterInfo{
+		ForceEndpoint: data.KubernetesEndpoint.Value,
+	}
+	resp.Diagnostics.Append(data.ControlPlaneNodes.ElementsAs(ctx, &clusterInfo.ControlPlaneNodes, false)...)
+	resp.Diagnostics.Append(data.WorkerNodes.ElementsAs(ctx, &clusterInfo.WorkerNodes, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := cluster.NewClusterServiceClient(conn)
+
+	checks, err := healthCheck(ctx, client, clusterInfo, timeout)
+	if err != nil {
+		var summary []string
+		for _, check := range checks {
+			summary = append(summary, fmt.Sprintf("%s: %s", check.Name.Value, check.Status.Value))
+		}
+		resp.Diagnostics.AddError(
+			"Cluster is not healthy",
+			fmt.Sprintf("%s\n\n%s", err, strings.Join(summary, "\n")),
+		)
+		return
+	}
+	data.Checks = checks
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "read a Talos cluster health data source")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// healthCheck runs the cluster health checks on the node and returns the last
+// status reported by each check, in the order they were run.
+func healthCheck(ctx context.Context, client cluster.ClusterServiceClient, clusterInfo *cluster.ClusterInfo, timeout time.Duration) ([]ClusterHealthCheckModel, error) {
+	stream, err := client.HealthCheck(ctx, &cluster.HealthCheckRequest{
+		WaitTimeout: durationpb.New(timeout),
+		ClusterInfo: clusterInfo,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []ClusterHealthCheckModel
+	index := map[string]int{}
+
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return checks, nil
+		}
+		if err != nil {
+			return checks, err
+		}
+		if msg.Metadata != nil && msg.Metadata.Error != "" {
+			return checks, fmt.Errorf("%s", msg.Metadata.Error)
+		}
+
+		// Progress messages look like "waiting for <check>: <status>".
+		name, status := strings.TrimPrefix(msg.Message, "waiting for "), ""
+		if i := strings.LastIndex(name, ": "); i >= 0 {
+			name, status = name[:i], name[i+2:]
+		}
+
+		check := ClusterHealthCheckModel{
+			Name:   types.String{Value: name},
+			Status: types.String{Value: status},
+		}
+		if i, ok := index[name]; ok {
+			checks[i] = check
+		} else {
+			index[name] = len(checks)
+			checks = append(checks, check)
+		}
+	}
+}
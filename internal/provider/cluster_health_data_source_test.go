@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccClusterHealthDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccClusterHealthDataSourceConfig(talos),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_cluster_health.test", "checks.#", "2"),
+					resource.TestCheckResourceAttr("data.talos_cluster_health.test", "checks.0.name", "etcd to be healthy"),
+					resource.TestCheckResourceAttr("data.talos_cluster_health.test", "checks.0.status", "OK"),
+					resource.TestCheckResourceAttr("data.talos_cluster_health.test", "checks.1.name", "all k8s nodes to report ready"),
+					resource.TestCheckResourceAttr("data.talos_cluster_health.test", "checks.1.status", "OK"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccClusterHealthDataSource_error(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("HealthCheck", status.Error(codes.DeadlineExceeded, "context deadline exceeded"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccClusterHealthDataSourceConfig(talos),
+				ExpectError: regexp.MustCompile("Cluster is not healthy"),
+			},
+		},
+	})
+}
+
+func testAccClusterHealthDataSourceConfig(talos *fakeTalos) string {
+	return fmt.Sprintf(`
+data "talos_cluster_health" "test" {%s
+  control_plane_nodes = ["10.5.0.2", "10.5.0.3"]
+  worker_nodes        = ["10.5.0.4"]
+}
+`, talos.testClientConfig())
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	cosiv1alpha1 "github.com/cosi-project/runtime/api/v1alpha1"
+	"gopkg.in/yaml.v3"
+)
+
+// listResources returns the COSI resources of the given namespace and type on
+// the node addressed by ctx.
+func listResources(ctx context.Context, client cosiv1alpha1.StateClient, namespace, resourceType string) ([]*cosiv1alpha1.Resource, error) {
+	stream, err := client.List(ctx, &cosiv1alpha1.ListRequest{
+		Namespace: namespace,
+		Type:      resourceType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*cosiv1alpha1.Resource
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg.GetResource() != nil {
+			resources = append(resources, msg.GetResource())
+		}
+	}
+}
+
+// decodeSpec decodes the YAML representation of the spec of res into out.
+func decodeSpec(res *cosiv1alpha1.Resource, out interface{}) error {
+	if err := yaml.Unmarshal([]byte(res.GetSpec().GetYamlSpec()), out); err != nil {
+		return fmt.Errorf("error decoding %s %s: %w", res.GetMetadata().GetType(), res.GetMetadata().GetId(), err)
+	}
+	return nil
+}
+
+// The resource definitions, as defined in
+// github.com/cosi-project/runtime/pkg/resource/meta.
+const (
+	metaNamespace          = "meta"
+	resourceDefinitionType = "ResourceDefinitions.meta.cosi.dev"
+)
+
+type resourceDefinitionSpec struct {
+	Type             string   `yaml:"type"`
+	DisplayType      string   `yaml:"displayType"`
+	Aliases          []string `yaml:"aliases"`
+	DefaultNamespace string   `yaml:"defaultNamespace"`
+}
+
+// resolveResourceType looks up the resource definition matching name, which
+// may be a type, a display type or an alias, as `talosctl get` does.
+func resolveResourceType(ctx context.Context, client cosiv1alpha1.StateClient, name string) (*resourceDefinitionSpec, error) {
+	definitions, err := listResources(ctx, client, metaNamespace, resourceDefinitionType)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.ToLower(name)
+	for _, res := range definitions {
+		var spec resourceDefinitionSpec
+		if err := decodeSpec(res, &spec); err != nil {
+			return nil, err
+		}
+
+		candidates := append([]string{spec.Type, spec.DisplayType}, spec.Aliases...)
+		for _, candidate := range candidates {
+			if strings.ToLower(candidate) == name {
+				return &spec, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("resource type %q is not registered", name)
+}
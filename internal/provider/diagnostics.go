@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/talos-systems/talos/pkg/machinery/api/common"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	tc "github.com/talos-systems/talos/pkg/machinery/client"
+	"github.com/talos-systems/talos/pkg/machinery/constants"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// diagnosticServices are the services whose logs are collected when a
+// request fails.
+var diagnosticServices = []string{"etcd", "apid", "trustd", "kubelet"}
+
+const (
+	// diagnosticTimeout bounds the time spent collecting diagnostics, as
+	// the node may be unhealthy.
+	diagnosticTimeout = 30 * time.Second
+	// diagnosticLogLines is the number of log lines of each service in the
+	// summary.
+	diagnosticLogLines = 10
+	// diagnosticKernelLines is the number of kernel log lines in the
+	// summary.
+	diagnosticKernelLines = 20
+)
+
+// collectDiagnostics collects the state of the services, the logs of the
+// main services and the kernel log of the node addressed by ctx, to explain
+// why a request failed. It returns a trimmed summary, and writes everything
+// to a support bundle in bundleDir if it is not empty.
+func collectDiagnostics(ctx context.Context, client machine.MachineServiceClient, bundleDir string) string {
+	ctx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+	defer cancel()
+
+	var summary strings.Builder
+	bundle := map[string][]byte{}
+
+	services, err := serviceListTable(ctx, client)
+	if err != nil {
+		fmt.Fprintf(&summary, "Could not list services: %s\n", err)
+	} else {
+		fmt.Fprintf(&summary, "Services:\n%s", services)
+		bundle["services.txt"] = []byte(services)
+	}
+
+	tailLines := int32(diagnosticLogLines)
+	if bundleDir != "" {
+		tailLines = -1
+	}
+	for _, id := range diagnosticServices {
+		logs, err := serviceLogs(ctx, client, id, tailLines)
+		if err != nil {
+			fmt.Fprintf(&summary, "\nCould not read %s logs: %s\n", id, err)
+			continue
+		}
+		fmt.Fprintf(&summary, "\n%s logs:\n%s", id, lastLines(logs, diagnosticLogLines))
+		bundle[id+".log"] = logs
+	}
+
+	dmesg, err := kernelLog(ctx, client)
+	if err != nil {
+		fmt.Fprintf(&summary, "\nCould not read kernel log: %s\n", err)
+	} else {
+		fmt.Fprintf(&summary, "\nKernel log:\n%s", lastLines(dmesg, diagnosticKernelLines))
+		bundle["dmesg.log"] = dmesg
+	}
+
+	if bundleDir != "" {
+		if err := writeSupportBundle(bundleDir, bundle); err != nil {
+			fmt.Fprintf(&summary, "\nCould not write support bundle: %s\n", err)
+		} else {
+			fmt.Fprintf(&summary, "\nSupport bundle written to %s\n", bundleDir)
+		}
+	}
+
+	return summary.String()
+}
+
+// withDiagnostics appends the diagnostics collected from the node addressed
+// by ctx to the detail of a failure.
+func withDiagnostics(ctx context.Context, client machine.MachineServiceClient, bundleDir string, err error) string {
+	return fmt.Sprintf("%s\n\n%s", err, collectDiagnostics(ctx, client, bundleDir))
+}
+
+// serviceListTable returns the state and health of the services as a table.
+func serviceListTable(ctx context.Context, client machine.MachineServiceClient) (string, error) {
+	resp, err := client.ServiceList(ctx, &emptypb.Empty{})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, msg := range resp.Messages {
+		for _, svc := range msg.Services {
+			health := "?"
+			if svc.Health != nil && !svc.Health.Unknown {
+				health = "OK"
+				if !svc.Health.Healthy {
+					health = "Fail " + svc.Health.LastMessage
+				}
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", svc.Id, svc.State, health)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// serviceLogs returns the last tailLines lines of the logs of a Talos
+// service, or all of them if tailLines is -1.
+func serviceLogs(ctx context.Context, client machine.MachineServiceClient, id string, tailLines int32) ([]byte, error) {
+	stream, err := client.Logs(ctx, &machine.LogsRequest{
+		Namespace: constants.SystemContainerdNamespace,
+		Id:        id,
+		Driver:    common.ContainerDriver_CONTAINERD,
+		TailLines: tailLines,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return readAllStream(stream, noSizeLimit)
+}
+
+// kernelLog returns the kernel log.
+func kernelLog(ctx context.Context, client machine.MachineServiceClient) ([]byte, error) {
+	stream, err := client.Dmesg(ctx, &machine.DmesgRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return readAllStream(stream, noSizeLimit)
+}
+
+// noSizeLimit disables the size limit of readAllStream and readFile.
+const noSizeLimit = -1
+
+// readAllStream reads a data stream to the end, failing if it is larger than
+// maxSize bytes unless maxSize is noSizeLimit. The caller should cancel the
+// context of the stream when it fails, so that the node stops sending it.
+func readAllStream(stream tc.MachineStream, maxSize int64) ([]byte, error) {
+	r, errCh, err := tc.ReadStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	var limited io.Reader = r
+	if maxSize != noSizeLimit {
+		limited = io.LimitReader(r, maxSize+1)
+	}
+	content, err := io.ReadAll(limited)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize != noSizeLimit && int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("larger than %d bytes", maxSize)
+	}
+
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// lastLines returns the last n lines of content, indented.
+func lastLines(content []byte, n int) string {
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		if line != "" {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	return b.String()
+}
+
+// writeSupportBundle writes the collected files to dir.
+func writeSupportBundle(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+)
+
+func TestCollectDiagnostics(t *testing.T) {
+	talos := newFakeTalos(t)
+	client := testMachineClient(t, talos)
+	bundleDir := filepath.Join(t.TempDir(), "bundle")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, dir := range []string{"", bundleDir} {
+		summary := collectDiagnostics(ctx, client, dir)
+
+		for _, expected := range []string{
+			"Services:\n  etcd     Running  OK\n",
+			"\netcd logs:\n  etcd line 16\n",
+			"  etcd line 25\n",
+			"\nCould not read apid logs: ",
+			"\nKernel log:\n  kernel line 6\n",
+		} {
+			if !strings.Contains(summary, expected) {
+				t.Errorf("summary does not contain %q:\n%s", expected, summary)
+			}
+		}
+		if strings.Contains(summary, "etcd line 15\n") {
+			t.Errorf("summary is not trimmed to %d log lines:\n%s", diagnosticLogLines, summary)
+		}
+		if written := strings.Contains(summary, "Support bundle written to "+bundleDir); written != (dir != "") {
+			t.Errorf("unexpected support bundle report in:\n%s", summary)
+		}
+	}
+
+	for name, lines := range map[string]int{"etcd.log": testLogLines, "kubelet.log": testLogLines, "dmesg.log": testLogLines} {
+		content, err := os.ReadFile(filepath.Join(bundleDir, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if n := strings.Count(string(content), "\n"); n != lines {
+			t.Errorf("%s has %d lines, expected %d", name, n, lines)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(bundleDir, "apid.log")); !os.IsNotExist(err) {
+		t.Errorf("apid.log should not be written: %v", err)
+	}
+}
+
+func TestReadAllStream(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetFile("/var/test", []byte("0123456789"))
+	client := testMachineClient(t, talos)
+
+	for maxSize, fail := range map[int64]bool{noSizeLimit: false, 10: false, 9: true} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		stream, err := client.Read(ctx, &machine.ReadRequest{Path: "/var/test"})
+		if err != nil {
+			cancel()
+			t.Fatal(err)
+		}
+		content, err := readAllStream(stream, maxSize)
+		cancel()
+
+		switch {
+		case fail && err == nil:
+			t.Errorf("reading with a limit of %d bytes should fail", maxSize)
+		case !fail && err != nil:
+			t.Errorf("reading with a limit of %d bytes: %s", maxSize, err)
+		case !fail && string(content) != "0123456789":
+			t.Errorf("read %q with a limit of %d bytes", content, maxSize)
+		}
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	if got, expected := lastLines([]byte("a\nb\n\nc\n"), 2), "  c\n"; got != expected {
+		t.Errorf("lastLines = %q, expected %q", got, expected)
+	}
+	if got, expected := lastLines([]byte("a\nb"), 5), "  a\n  b\n"; got != expected {
+		t.Errorf("lastLines = %q, expected %q", got, expected)
+	}
+}
+
+// testMachineClient returns a client of the machine service of talos.
+func testMachineClient(t *testing.T, talos *fakeTalos) machine.MachineServiceClient {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, diags := dialTalos(ctx, types.String{Value: talos.Endpoint}, types.List{ElemType: types.StringType, Null: true}, types.String{Value: talos.CA}, types.String{Value: talos.Crt}, types.String{Value: talos.Key})
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return machine.NewMachineServiceClient(conn)
+}
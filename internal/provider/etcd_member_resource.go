@@ -0,0 +1,290 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var _ resource.Resource = &EtcdMemberResource{}
+
+func NewEtcdMemberResource() resource.Resource {
+	return &EtcdMemberResource{}
+}
+
+type EtcdMemberResource struct{}
+
+type EtcdMemberResourceModel struct {
+	ID         types.String `tfsdk:"id"`
+	Endpoint   types.String `tfsdk:"endpoint"`
+	Endpoints  types.List   `tfsdk:"endpoints"`
+	MachineCa  types.String `tfsdk:"machine_ca"`
+	MachineCrt types.String `tfsdk:"machine_crt"`
+	MachineKey types.String `tfsdk:"machine_key"`
+	Node       types.String `tfsdk:"node"`
+	Hostname   types.String `tfsdk:"hostname"`
+	MemberID   types.String `tfsdk:"member_id"`
+}
+
+func (r *EtcdMemberResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_etcd_member"
+}
+
+func (r *EtcdMemberResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Track the etcd membership of a control plane node. " +
+			"Destroying the resource makes the node leave the etcd cluster or, if the node is unreachable, removes its member through `endpoint`, " +
+			"which should then be a control plane node that is kept.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"endpoint": targetEndpointAttribute("node"),
+			"node": {
+				MarkdownDescription: "Control plane node member of the etcd cluster, reached through `endpoint`.",
+				Required:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
+			},
+			"hostname": {
+				Computed:            true,
+				MarkdownDescription: "Hostname of the etcd member.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"member_id": {
+				Computed:            true,
+				MarkdownDescription: "ID of the etcd member, in hexadecimal.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+		}),
+	}, nil
+}
+
+func (r *EtcdMemberResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		clientCredentialsValidator{adminRoles},
+	}
+}
+
+func (r *EtcdMemberResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *EtcdMemberResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	hostnameResp, err := client.Hostname(nodeContext(ctx, data.Node.Value), &emptypb.Empty{})
+	if err == nil && len(hostnameResp.Messages) == 0 {
+		err = errors.New("empty hostname response")
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading hostname",
+			err.Error(),
+		)
+		return
+	}
+	hostname := hostnameResp.Messages[0].Hostname
+
+	member, err := findEtcdMember(ctx, client, hostname)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error listing etcd members",
+			err.Error(),
+		)
+		return
+	}
+	if member == nil {
+		resp.Diagnostics.AddError(
+			"Node is not an etcd member",
+			fmt.Sprintf("No etcd member has hostname %s. Make sure the node is a control plane node and the cluster is bootstrapped.", hostname),
+		)
+		return
+	}
+
+	data.Hostname = types.String{Value: member.Hostname}
+	data.MemberID = types.String{Value: etcdMemberID(member.Id)}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a Talos etcd member resource")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *EtcdMemberResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *EtcdMemberResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	member, err := findEtcdMember(ctx, client, data.Hostname.Value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error listing etcd members",
+			err.Error(),
+		)
+		return
+	}
+	if member == nil {
+		tflog.Info(ctx, "etcd member not found", map[string]interface{}{"hostname": data.Hostname.Value})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	data.MemberID = types.String{Value: etcdMemberID(member.Id)}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *EtcdMemberResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *EtcdMemberResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *EtcdMemberResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *EtcdMemberResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	leaveErr := etcdLeaveCluster(nodeContext(ctx, data.Node.Value), client)
+	if leaveErr == nil {
+		return
+	}
+	tflog.Warn(ctx, "etcd member could not leave the cluster, removing it", map[string]interface{}{"hostname": data.Hostname.Value, "error": leaveErr.Error()})
+
+	member, err := findEtcdMember(ctx, client, data.Hostname.Value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error listing etcd members",
+			fmt.Sprintf("%s\n\nThe node could not leave the cluster: %s", err, leaveErr),
+		)
+		return
+	}
+	if member == nil {
+		return
+	}
+
+	removeResp, err := client.EtcdRemoveMember(ctx, &machine.EtcdRemoveMemberRequest{
+		Member: member.Hostname,
+	})
+	if err == nil {
+		for _, msg := range removeResp.Messages {
+			if msg.Metadata != nil && msg.Metadata.Error != "" {
+				err = errors.New(msg.Metadata.Error)
+			}
+		}
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error removing etcd member",
+			fmt.Sprintf("%s\n\nThe node could not leave the cluster: %s", err, leaveErr),
+		)
+		return
+	}
+}
+
+// findEtcdMember returns the etcd member with the given hostname, or nil if
+// there is none.
+func findEtcdMember(ctx context.Context, client machine.MachineServiceClient, hostname string) (*machine.EtcdMember, error) {
+	members, err := etcdMembers(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		if strings.EqualFold(member.Hostname, hostname) {
+			return member, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// etcdLeaveCluster makes the node addressed by ctx leave the etcd cluster.
+func etcdLeaveCluster(ctx context.Context, client machine.MachineServiceClient) error {
+	resp, err := client.EtcdLeaveCluster(ctx, &machine.EtcdLeaveClusterRequest{})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range resp.Messages {
+		if msg.Metadata != nil && msg.Metadata.Error != "" {
+			return errors.New(msg.Metadata.Error)
+		}
+	}
+
+	return nil
+}
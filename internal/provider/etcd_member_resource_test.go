@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccEtcdMemberResource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testCheckEtcdMemberDestroyed(talos, "talos-cp-1"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccEtcdMemberResourceConfig(talos),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_etcd_member.test", "hostname", "talos-cp-1"),
+					resource.TestCheckResourceAttr("talos_etcd_member.test", "member_id", "1f3a"),
+				),
+			},
+		},
+	})
+
+	if calls := talos.Calls("EtcdLeaveCluster"); calls != 1 {
+		t.Errorf("EtcdLeaveCluster called %d times, expected 1", calls)
+	}
+}
+
+func TestAccEtcdMemberResource_removeMember(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("EtcdLeaveCluster", status.Error(codes.Unavailable, "etcd is not running"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy:             testCheckEtcdMemberDestroyed(talos, "talos-cp-1"),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccEtcdMemberResourceConfig(talos),
+			},
+		},
+	})
+
+	if calls := talos.Calls("EtcdRemoveMember"); calls != 1 {
+		t.Errorf("EtcdRemoveMember called %d times, expected 1", calls)
+	}
+}
+
+func testAccEtcdMemberResourceConfig(talos *fakeTalos) string {
+	return fmt.Sprintf(`
+resource "talos_etcd_member" "test" {%s
+  node = "10.5.0.2"
+}
+`, talos.testClientConfig())
+}
+
+// testCheckEtcdMemberDestroyed checks that the member with hostname is no
+// longer in the etcd cluster of the fake Talos API.
+func testCheckEtcdMemberDestroyed(talos *fakeTalos, hostname string) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		for _, member := range talos.EtcdMembers() {
+			if member == hostname {
+				return fmt.Errorf("etcd member %s still in the cluster", hostname)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+)
+
+var _ datasource.DataSource = &EtcdMembersDataSource{}
+
+func NewEtcdMembersDataSource() datasource.DataSource {
+	return &EtcdMembersDataSource{}
+}
+
+type EtcdMembersDataSource struct{}
+
+type EtcdMembersDataSourceModel struct {
+	ID         types.String      `tfsdk:"id"`
+	Endpoint   types.String      `tfsdk:"endpoint"`
+	Endpoints  types.List        `tfsdk:"endpoints"`
+	MachineCa  types.String      `tfsdk:"machine_ca"`
+	MachineCrt types.String      `tfsdk:"machine_crt"`
+	MachineKey types.String      `tfsdk:"machine_key"`
+	Node       types.String      `tfsdk:"node"`
+	Members    []EtcdMemberModel `tfsdk:"members"`
+}
+
+type EtcdMemberModel struct {
+	ID         types.String `tfsdk:"id"`
+	Hostname   types.String `tfsdk:"hostname"`
+	PeerUrls   types.List   `tfsdk:"peer_urls"`
+	ClientUrls types.List   `tfsdk:"client_urls"`
+	IsLearner  types.Bool   `tfsdk:"is_learner"`
+}
+
+func (d *EtcdMembersDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_etcd_members"
+}
+
+func (d *EtcdMembersDataSource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "List the members of the etcd cluster.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				Type:                types.StringType,
+			},
+			"node": {
+				MarkdownDescription: "Control plane node to query through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"members": {
+				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+					"id": {
+						Computed:            true,
+						MarkdownDescription: "ID of the member, in hexadecimal.",
+						Type:                types.StringType,
+					},
+					"hostname": {
+						Computed:            true,
+						MarkdownDescription: "Hostname of the member.",
+						Type:                types.StringType,
+					},
+					"peer_urls": {
+						Computed:            true,
+						MarkdownDescription: "URLs the member uses to communicate with the other members.",
+						Type: types.ListType{
+							ElemType: types.StringType,
+						},
+					},
+					"client_urls": {
+						Computed:            true,
+						MarkdownDescription: "URLs the member exposes to clients.",
+						Type: types.ListType{
+							ElemType: types.StringType,
+						},
+					},
+					"is_learner": {
+						Computed:            true,
+						MarkdownDescription: "Whether the member is a learner, not yet promoted to a voting member.",
+						Type:                types.BoolType,
+					},
+				}),
+				Computed:            true,
+				MarkdownDescription: "Members of the etcd cluster.",
+			},
+		}),
+	}, nil
+}
+
+func (d *EtcdMembersDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		clientCredentialsValidator{readerRoles},
+	}
+}
+
+func (d *EtcdMembersDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data *EtcdMembersDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	members, err := etcdMembers(nodeContext(ctx, data.Node.Value), client)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error listing etcd members",
+			err.Error(),
+		)
+		return
+	}
+
+	data.Members = nil
+	for _, member := range members {
+		data.Members = append(data.Members, EtcdMemberModel{
+			ID:         types.String{Value: etcdMemberID(member.Id)},
+			Hostname:   types.String{Value: member.Hostname},
+			PeerUrls:   stringList(member.PeerUrls),
+			ClientUrls: stringList(member.ClientUrls),
+			IsLearner:  types.Bool{Value: member.IsLearner},
+		})
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "read a Talos etcd members data source")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// etcdMembers returns the members of the etcd cluster, as seen by the node
+// addressed by ctx.
+func etcdMembers(ctx context.Context, client machine.MachineServiceClient) ([]*machine.EtcdMember, error) {
+	resp, err := client.EtcdMemberList(ctx, &machine.EtcdMemberListRequest{})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Messages) == 0 {
+		return nil, errors.New("empty etcd member list response")
+	}
+	if md := resp.Messages[0].Metadata; md != nil && md.Error != "" {
+		return nil, errors.New(md.Error)
+	}
+	return resp.Messages[0].Members, nil
+}
+
+// etcdMemberID formats a member ID as etcdctl does.
+func etcdMemberID(id uint64) string {
+	return fmt.Sprintf("%x", id)
+}
+
+// stringList converts values to a list of strings.
+func stringList(values []string) types.List {
+	elems := make([]attr.Value, 0, len(values))
+	for _, value := range values {
+		elems = append(elems, types.String{Value: value})
+	}
+	return types.List{ElemType: types.StringType, Elems: elems}
+}
@@ -0,0 +1,32 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccEtcdMembersDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+data "talos_etcd_members" "test" {%s}
+`, talos.testClientConfig()),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_etcd_members.test", "members.#", "2"),
+					resource.TestCheckResourceAttr("data.talos_etcd_members.test", "members.0.id", "1f3a"),
+					resource.TestCheckResourceAttr("data.talos_etcd_members.test", "members.0.hostname", "talos-cp-1"),
+					resource.TestCheckResourceAttr("data.talos_etcd_members.test", "members.0.peer_urls.0", "https://10.5.0.2:2380"),
+					resource.TestCheckResourceAttr("data.talos_etcd_members.test", "members.1.id", "2b7c"),
+					resource.TestCheckResourceAttr("data.talos_etcd_members.test", "members.1.client_urls.0", "https://10.5.0.3:2379"),
+				),
+			},
+		},
+	})
+}
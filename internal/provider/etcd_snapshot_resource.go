@@ -0,0 +1,290 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	tc "github.com/talos-systems/talos/pkg/machinery/client"
+	bolt "go.etcd.io/bbolt"
+)
+
+var _ resource.Resource = &EtcdSnapshotResource{}
+
+func NewEtcdSnapshotResource() resource.Resource {
+	return &EtcdSnapshotResource{}
+}
+
+type EtcdSnapshotResource struct{}
+
+type EtcdSnapshotResourceModel struct {
+	ID         types.String `tfsdk:"id"`
+	Endpoint   types.String `tfsdk:"endpoint"`
+	Endpoints  types.List   `tfsdk:"endpoints"`
+	MachineCa  types.String `tfsdk:"machine_ca"`
+	MachineCrt types.String `tfsdk:"machine_crt"`
+	MachineKey types.String `tfsdk:"machine_key"`
+	Node       types.String `tfsdk:"node"`
+	Path       types.String `tfsdk:"path"`
+	Triggers   types.Map    `tfsdk:"triggers"`
+	Size       types.Int64  `tfsdk:"size"`
+	Sha256     types.String `tfsdk:"sha256"`
+	Revision   types.Int64  `tfsdk:"revision"`
+}
+
+func (r *EtcdSnapshotResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_etcd_snapshot"
+}
+
+func (r *EtcdSnapshotResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Take a snapshot of the etcd database of a control plane node and save it to a local file. " +
+			"The file is kept when the resource is destroyed.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"endpoint": targetEndpointAttribute("node"),
+			"node": {
+				MarkdownDescription: "Control plane node to take the snapshot from, reached through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
+			},
+			"path": {
+				MarkdownDescription: "Local path to save the snapshot to.",
+				Required:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
+			},
+			"triggers": {
+				MarkdownDescription: "Arbitrary values that, when changed, take a new snapshot.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.MapType{
+					ElemType: types.StringType,
+				},
+			},
+			"size": {
+				Computed:            true,
+				MarkdownDescription: "Size of the snapshot, in bytes.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.Int64Type,
+			},
+			"sha256": {
+				Computed:            true,
+				MarkdownDescription: "SHA-256 checksum of the snapshot.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"revision": {
+				Computed:            true,
+				MarkdownDescription: "etcd revision of the snapshot.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.Int64Type,
+			},
+		}),
+	}, nil
+}
+
+func (r *EtcdSnapshotResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		clientCredentialsValidator{etcdBackupRoles},
+	}
+}
+
+func (r *EtcdSnapshotResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *EtcdSnapshotResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	size, sum, err := downloadEtcdSnapshot(nodeContext(ctx, data.Node.Value), client, data.Path.Value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error taking etcd snapshot",
+			err.Error(),
+		)
+		return
+	}
+
+	revision, err := snapshotRevision(data.Path.Value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading etcd snapshot revision",
+			err.Error(),
+		)
+		return
+	}
+
+	data.Size = types.Int64{Value: size}
+	data.Sha256 = types.String{Value: sum}
+	data.Revision = types.Int64{Value: revision}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a Talos etcd snapshot resource")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *EtcdSnapshotResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *EtcdSnapshotResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Take a new snapshot if the file is gone.
+	if _, err := os.Stat(data.Path.Value); errors.Is(err, os.ErrNotExist) {
+		tflog.Info(ctx, "etcd snapshot file not found", map[string]interface{}{"path": data.Path.Value})
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *EtcdSnapshotResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *EtcdSnapshotResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *EtcdSnapshotResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *EtcdSnapshotResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// downloadEtcdSnapshot streams an etcd snapshot of the node addressed by ctx
+// to path, and returns its size and SHA-256 checksum. The file is only
+// replaced once the whole snapshot has been received.
+func downloadEtcdSnapshot(ctx context.Context, client machine.MachineServiceClient, path string) (int64, string, error) {
+	stream, err := client.EtcdSnapshot(ctx, &machine.EtcdSnapshotRequest{})
+	if err != nil {
+		return 0, "", err
+	}
+
+	r, errCh, err := tc.ReadStream(stream)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer r.Close()
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".part")
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := <-errCh; err != nil {
+		return 0, "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return 0, "", err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return 0, "", err
+	}
+
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// snapshotRevision returns the etcd revision of the snapshot at path, as
+// `etcdutl snapshot status` does.
+func snapshotRevision(path string) (int64, error) {
+	db, err := bolt.Open(path, 0o400, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var revision int64
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("key"))
+		if bucket == nil {
+			return errors.New("snapshot has no key bucket")
+		}
+
+		// Keys are revisions: 8 bytes of main revision, '_' and 8 bytes of
+		// sub revision, all big endian.
+		if key, _ := bucket.Cursor().Last(); len(key) >= 8 {
+			revision = int64(binary.BigEndian.Uint64(key[:8]))
+		}
+		return nil
+	})
+
+	return revision, err
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccEtcdSnapshotResource(t *testing.T) {
+	talos := newFakeTalos(t)
+	path := filepath.Join(t.TempDir(), "etcd.snapshot")
+	sum := sha256.Sum256(talos.snapshot)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccEtcdSnapshotResourceConfig(talos, path),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_etcd_snapshot.test", "revision", "42"),
+					resource.TestCheckResourceAttr("talos_etcd_snapshot.test", "size", strconv.Itoa(len(talos.snapshot))),
+					resource.TestCheckResourceAttr("talos_etcd_snapshot.test", "sha256", hex.EncodeToString(sum[:])),
+					testCheckSnapshotFile(path, talos.snapshot),
+				),
+			},
+		},
+	})
+}
+
+func TestAccEtcdSnapshotResource_error(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("EtcdSnapshot", status.Error(codes.FailedPrecondition, "etcd is not running"))
+	path := filepath.Join(t.TempDir(), "etcd.snapshot")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccEtcdSnapshotResourceConfig(talos, path),
+				ExpectError: regexp.MustCompile("etcd is not running"),
+			},
+		},
+	})
+
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("snapshot %s written despite the error", path)
+	}
+}
+
+func testAccEtcdSnapshotResourceConfig(talos *fakeTalos, path string) string {
+	return fmt.Sprintf(`
+resource "talos_etcd_snapshot" "test" {%s
+  path = %q
+}
+`, talos.testClientConfig(), path)
+}
+
+// testCheckSnapshotFile checks that the snapshot at path has content.
+func testCheckSnapshotFile(path string, content []byte) resource.TestCheckFunc {
+	return func(*terraform.State) error {
+		written, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if string(written) != string(content) {
+			return fmt.Errorf("snapshot %s differs from the one of the node", path)
+		}
+		return nil
+	}
+}
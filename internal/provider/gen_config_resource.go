@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/hashicorp/terraform-plugin-framework/diag"
@@ -17,10 +18,13 @@ import (
 	"github.com/talos-systems/talos/pkg/machinery/config/types/v1alpha1/generate"
 	"github.com/talos-systems/talos/pkg/machinery/constants"
 	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_validator"
 	"gopkg.in/yaml.v3"
 )
 
 var _ resource.Resource = &GenConfigResource{}
+var _ resource.ResourceWithConfigValidators = &GenConfigResource{}
+var _ resource.ResourceWithModifyPlan = &GenConfigResource{}
 
 func NewGenConfigResource() resource.Resource {
 	return &GenConfigResource{}
@@ -29,6 +33,7 @@ func NewGenConfigResource() resource.Resource {
 type GenConfigResource struct{}
 
 type GenConfigResourceModel struct {
+	ID                      types.String `tfsdk:"id"`
 	ClusterName             types.String `tfsdk:"cluster_name"`
 	ClusterEndpoint         types.String `tfsdk:"cluster_endpoint"`
 	KubernetesVersion       types.String `tfsdk:"kubernetes_version"`
@@ -41,12 +46,16 @@ type GenConfigResourceModel struct {
 	InstallDisk             types.String `tfsdk:"install_disk"`
 	InstallImage            types.String `tfsdk:"install_image"`
 	AdditionalSans          types.List   `tfsdk:"additional_sans"`
+	PodSubnets              types.List   `tfsdk:"pod_subnets"`
+	ServiceSubnets          types.List   `tfsdk:"service_subnets"`
 	DnsDomain               types.String `tfsdk:"dns_domain"`
 	Persist                 types.Bool   `tfsdk:"persist"`
 	WithClusterDiscovery    types.Bool   `tfsdk:"with_cluster_discovery"`
 	TalosVersion            types.String `tfsdk:"talos_version"`
 	RegistryMirrors         types.Map    `tfsdk:"registry_mirrors"`
 	WithKubespan            types.Bool   `tfsdk:"with_kubespan"`
+	Secrets                 types.String `tfsdk:"secrets"`
+	GeneratedSecrets        types.String `tfsdk:"generated_secrets"`
 }
 
 func (r *GenConfigResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
@@ -58,15 +67,32 @@ func (r *GenConfigResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.D
 		MarkdownDescription: "Generates a configuration for Talos cluster.",
 
 		Attributes: map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `cluster_name`.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
 			"cluster_name": {
 				MarkdownDescription: "Cluster name.",
 				Required:            true,
-				Type:                types.StringType,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
 			},
 			"cluster_endpoint": {
 				MarkdownDescription: "Cluster endpoint.",
 				Required:            true,
-				Type:                types.StringType,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
+				Validators: []tfsdk.AttributeValidator{
+					attribute_validator.URL(true, "https"),
+				},
 			},
 			"kubernetes_version": {
 				Computed:            true,
@@ -76,24 +102,42 @@ func (r *GenConfigResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.D
 					attribute_plan_modifier.DefaultValue(types.String{Value: constants.DefaultKubernetesVersion}),
 				},
 				Type: types.StringType,
+				Validators: []tfsdk.AttributeValidator{
+					attribute_validator.Semver(),
+				},
 			},
 			"config_patch": {
+				Computed:            true,
 				MarkdownDescription: "Patch generated machineconfigs (applied to all node types).",
 				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.List{ElemType: types.StringType, Null: true}),
+					attribute_plan_modifier.SemanticEquality(),
+				},
 				Type: types.ListType{
 					ElemType: types.StringType,
 				},
 			},
 			"config_patch_control_plane": {
+				Computed:            true,
 				MarkdownDescription: "Patch generated machineconfigs (applied to 'init' and 'controlplane' types).",
 				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.List{ElemType: types.StringType, Null: true}),
+					attribute_plan_modifier.SemanticEquality(),
+				},
 				Type: types.ListType{
 					ElemType: types.StringType,
 				},
 			},
 			"config_patch_worker": {
+				Computed:            true,
 				MarkdownDescription: "Patch generated machineconfigs (applied to 'worker' type).",
 				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.List{ElemType: types.StringType, Null: true}),
+					attribute_plan_modifier.SemanticEquality(),
+				},
 				Type: types.ListType{
 					ElemType: types.StringType,
 				},
@@ -138,6 +182,26 @@ func (r *GenConfigResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.D
 					ElemType: types.StringType,
 				},
 			},
+			"pod_subnets": {
+				MarkdownDescription: "The subnets of the pods in CIDR notation, `10.244.0.0/16` (`fc00:db8:10::/56` for an IPv6 `cluster_endpoint`) when not set.",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+				Validators: []tfsdk.AttributeValidator{
+					attribute_validator.CIDR(),
+				},
+			},
+			"service_subnets": {
+				MarkdownDescription: "The subnets of the services in CIDR notation, `10.96.0.0/12` (`fc00:db8:20::/112` for an IPv6 `cluster_endpoint`) when not set.",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+				Validators: []tfsdk.AttributeValidator{
+					attribute_validator.CIDR(),
+				},
+			},
 			"dns_domain": {
 				Computed:            true,
 				MarkdownDescription: "The dns domain to use for cluster.",
@@ -166,9 +230,12 @@ func (r *GenConfigResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.D
 				Type: types.BoolType,
 			},
 			"talos_version": {
-				MarkdownDescription: "The desired Talos version to generate config for (backwards compatibility, e.g. v0.8).",
+				MarkdownDescription: "The desired Talos version to generate config for (backwards compatibility, e.g. v0.8). Versions newer than v1.2 are accepted and generate the same config as v1.2.",
 				Optional:            true,
 				Type:                types.StringType,
+				Validators: []tfsdk.AttributeValidator{
+					attribute_validator.TalosVersion(),
+				},
 			},
 			"registry_mirrors": {
 				MarkdownDescription: "List of registry mirrors to use in format: <registry host>=<mirror URL>.",
@@ -176,6 +243,9 @@ func (r *GenConfigResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.D
 				Type: types.MapType{
 					ElemType: types.StringType,
 				},
+				Validators: []tfsdk.AttributeValidator{
+					attribute_validator.URL(false, "http", "https"),
+				},
 			},
 			"with_kubespan": {
 				Computed:            true,
@@ -186,10 +256,67 @@ func (r *GenConfigResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.D
 				},
 				Type: types.BoolType,
 			},
+			"secrets": {
+				Computed:            true,
+				MarkdownDescription: "Secrets bundle in YAML, as generated by `talosctl gen secrets`. When not set, a secrets bundle is generated with the resource and kept in `generated_secrets`.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.String{Null: true}),
+					attribute_plan_modifier.SemanticEquality(),
+					resource.RequiresReplace(),
+				},
+				Sensitive: true,
+				Type:      types.StringType,
+			},
+			"generated_secrets": {
+				Computed:            true,
+				MarkdownDescription: "Secrets bundle in YAML generated when `secrets` is not set, reused by the updates of the resource so that the cluster keeps its certificates and tokens.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Sensitive: true,
+				Type:      types.StringType,
+			},
 		},
 	}, nil
 }
 
+func (r *GenConfigResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return genConfigValidators
+}
+
+// ModifyPlan keeps the generated configurations when the inputs are unchanged,
+// which happens when the semantic equality plan modifiers kept the prior
+// state of reformatted patches or secrets: the framework marks the computed
+// attributes as unknown before, as the configuration differs from the state.
+func (r *GenConfigResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan, state *GenConfigResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.ID = state.ID
+	plan.ControlPlaneConfig = state.ControlPlaneConfig
+	plan.WorkerConfig = state.WorkerConfig
+	plan.TalosConfig = state.TalosConfig
+	plan.GeneratedSecrets = state.GeneratedSecrets
+
+	unchanged := req.Plan
+	resp.Diagnostics.Append(unchanged.Set(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if unchanged.Raw.Equal(req.State.Raw) {
+		resp.Plan = unchanged
+	}
+}
+
 func (r *GenConfigResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var data *GenConfigResourceModel
 
@@ -200,13 +327,75 @@ func (r *GenConfigResource) Create(ctx context.Context, req resource.CreateReque
 		return
 	}
 
-	var genOptions []generate.GenOption
+	resp.Diagnostics.Append(generateConfig(ctx, data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	data.ID = types.String{Value: data.ClusterName.Value}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a Talos cluster configuration resource")
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *GenConfigResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *GenConfigResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
-	var registryMirrors map[string]string
-	resp.Diagnostics.Append(data.RegistryMirrors.ElementsAs(ctx, &registryMirrors, false)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *GenConfigResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *GenConfigResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(generateConfig(ctx, data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *GenConfigResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *GenConfigResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// generateConfig generates the machine and Talos configurations described by
+// data, and stores them in its computed attributes.
+func generateConfig(ctx context.Context, data *GenConfigResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+	var genOptions []generate.GenOption
+
+	var registryMirrors map[string]string
+	diags.Append(data.RegistryMirrors.ElementsAs(ctx, &registryMirrors, false)...)
+	if diags.HasError() {
+		return diags
+	}
 	for key, value := range registryMirrors {
 		genOptions = append(genOptions, generate.WithRegistryMirror(key, value))
 	}
@@ -214,16 +403,54 @@ func (r *GenConfigResource) Create(ctx context.Context, req resource.CreateReque
 	if !data.TalosVersion.Null {
 		versionContract, err := config.ParseContractFromVersion(data.TalosVersion.Value)
 		if err != nil {
-			resp.Diagnostics.AddError(
+			diags.AddError(
 				"Error parsing Talos version",
 				err.Error(),
 			)
-			return
+			return diags
 		}
 
 		genOptions = append(genOptions, generate.WithVersionContract(versionContract))
 	}
 
+	// The secrets generated with the resource are reused by its updates, new
+	// ones would not match the certificates and tokens of the cluster.
+	secretsBundle := data.Secrets
+	if data.Secrets.Null {
+		if data.GeneratedSecrets.Unknown || data.GeneratedSecrets.Null {
+			secrets, err := generate.NewSecretsBundle(generate.NewClock(), genOptions...)
+			if err != nil {
+				diags.AddError(
+					"Error generating secrets bundle",
+					err.Error(),
+				)
+				return diags
+			}
+			content, err := yaml.Marshal(secrets)
+			if err != nil {
+				diags.AddError(
+					"Error converting secrets bundle to YAML",
+					err.Error(),
+				)
+				return diags
+			}
+			data.GeneratedSecrets = types.String{Value: string(content)}
+		}
+		secretsBundle = data.GeneratedSecrets
+	} else {
+		data.GeneratedSecrets = types.String{Null: true}
+	}
+
+	secrets, err := parseSecretsBundle(secretsBundle.Value)
+	if err != nil {
+		diags.AddError(
+			"Error parsing secrets bundle",
+			err.Error(),
+		)
+		return diags
+	}
+	genOptions = append(genOptions, withSecretsBundle(secrets))
+
 	if !data.WithKubespan.Null && data.WithKubespan.Value {
 		genOptions = append(genOptions,
 			generate.WithNetworkOptions(
@@ -233,9 +460,9 @@ func (r *GenConfigResource) Create(ctx context.Context, req resource.CreateReque
 	}
 
 	var additionalSans []string
-	resp.Diagnostics.Append(data.AdditionalSans.ElementsAs(ctx, &additionalSans, false)...)
-	if resp.Diagnostics.HasError() {
-		return
+	diags.Append(data.AdditionalSans.ElementsAs(ctx, &additionalSans, false)...)
+	if diags.HasError() {
+		return diags
 	}
 	genOptions = append(genOptions,
 		generate.WithInstallDisk(data.InstallDisk.Value),
@@ -247,13 +474,21 @@ func (r *GenConfigResource) Create(ctx context.Context, req resource.CreateReque
 	)
 
 	var configPatch []string
-	resp.Diagnostics.Append(data.ConfigPatch.ElementsAs(ctx, &configPatch, false)...)
+	diags.Append(data.ConfigPatch.ElementsAs(ctx, &configPatch, false)...)
 	var configPatchControlPlane []string
-	resp.Diagnostics.Append(data.ConfigPatchControlPlane.ElementsAs(ctx, &configPatchControlPlane, false)...)
+	diags.Append(data.ConfigPatchControlPlane.ElementsAs(ctx, &configPatchControlPlane, false)...)
 	var configPatchWorker []string
-	resp.Diagnostics.Append(data.ConfigPatchWorker.ElementsAs(ctx, &configPatchWorker, false)...)
-	if resp.Diagnostics.HasError() {
-		return
+	diags.Append(data.ConfigPatchWorker.ElementsAs(ctx, &configPatchWorker, false)...)
+	var podSubnets, serviceSubnets []string
+	diags.Append(data.PodSubnets.ElementsAs(ctx, &podSubnets, false)...)
+	diags.Append(data.ServiceSubnets.ElementsAs(ctx, &serviceSubnets, false)...)
+	if diags.HasError() {
+		return diags
+	}
+	// The subnets are not generation options, they are patched into the
+	// configurations before the patches of the user.
+	if subnetsPatch := subnetsPatch(podSubnets, serviceSubnets); subnetsPatch != "" {
+		configPatch = append([]string{subnetsPatch}, configPatch...)
 	}
 	configBundle, err := mgmt.GenV1Alpha1Config(
 		genOptions,
@@ -265,86 +500,102 @@ func (r *GenConfigResource) Create(ctx context.Context, req resource.CreateReque
 		configPatchWorker,
 	)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		diags.AddError(
 			"Error generating config",
 			err.Error(),
 		)
-		return
+		return diags
 	}
 
 	controlPlaneConfig, err := yaml.Marshal(configBundle.ControlPlaneCfg)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		diags.AddError(
 			"Error converting control plane configuration to YAML",
 			err.Error(),
 		)
-		return
+		return diags
 	}
 	data.ControlPlaneConfig = types.String{Value: string(controlPlaneConfig)}
 
 	workerConfig, err := yaml.Marshal(configBundle.WorkerCfg)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		diags.AddError(
 			"Error converting worker configuration to YAML",
 			err.Error(),
 		)
-		return
+		return diags
 	}
 	data.WorkerConfig = types.String{Value: string(workerConfig)}
 
 	talosConfig, err := yaml.Marshal(configBundle.TalosCfg)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		diags.AddError(
 			"Error converting Talos configuration to YAML",
 			err.Error(),
 		)
-		return
+		return diags
 	}
 	data.TalosConfig = types.String{Value: string(talosConfig)}
 
-	// Write logs using the tflog package
-	// Documentation: https://terraform.io/plugin/log
-	tflog.Trace(ctx, "created a Talos cluster configuration resource")
-
-	// Save data into Terraform state
-	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	return diags
 }
 
-func (r *GenConfigResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
-	var data *GenConfigResourceModel
-
-	// Read Terraform prior state data into the model
-	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+// subnetsPatch returns a JSON patch replacing the pod and service subnets of
+// the cluster network with the non-empty given ones, or an empty string if
+// there are none.
+func subnetsPatch(podSubnets, serviceSubnets []string) string {
+	type operation struct {
+		Op    string   `json:"op"`
+		Path  string   `json:"path"`
+		Value []string `json:"value"`
+	}
 
-	if resp.Diagnostics.HasError() {
-		return
+	var ops []operation
+	if len(podSubnets) > 0 {
+		ops = append(ops, operation{"replace", "/cluster/network/podSubnets", podSubnets})
+	}
+	if len(serviceSubnets) > 0 {
+		ops = append(ops, operation{"replace", "/cluster/network/serviceSubnets", serviceSubnets})
+	}
+	if len(ops) == 0 {
+		return ""
 	}
 
-	// Save updated data into Terraform state
-	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+	// Marshaling strings and slices of strings cannot fail.
+	patch, _ := json.Marshal(ops)
+	return string(patch)
 }
 
-func (r *GenConfigResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
-	var data *GenConfigResourceModel
-
-	// Read Terraform plan data into the model
-	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+// parseSecretsBundle parses a secrets bundle generated by `talosctl gen
+// secrets`.
+func parseSecretsBundle(in string) (*generate.SecretsBundle, error) {
+	var secrets generate.SecretsBundle
+	if err := yaml.Unmarshal([]byte(in), &secrets); err != nil {
+		return nil, err
+	}
 
-	if resp.Diagnostics.HasError() {
-		return
+	switch {
+	case secrets.Cluster == nil:
+		return nil, fmt.Errorf("missing cluster")
+	case secrets.Secrets == nil:
+		return nil, fmt.Errorf("missing secrets")
+	case secrets.TrustdInfo == nil:
+		return nil, fmt.Errorf("missing trustdinfo")
+	case secrets.Certs == nil:
+		return nil, fmt.Errorf("missing certs")
 	}
 
-	// Save updated data into Terraform state
-	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
-}
+	secrets.Clock = generate.NewClock()
 
-func (r *GenConfigResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	var data *GenConfigResourceModel
+	return &secrets, nil
+}
 
-	// Read Terraform prior state data into the model
-	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+// withSecretsBundle generates configurations with the given secrets instead
+// of new ones.
+func withSecretsBundle(secrets *generate.SecretsBundle) generate.GenOption {
+	return func(o *generate.GenOptions) error {
+		o.Secrets = secrets
 
-	if resp.Diagnostics.HasError() {
-		return
+		return nil
 	}
 }
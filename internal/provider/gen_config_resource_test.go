@@ -0,0 +1,435 @@
+package provider
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// goldenContracts are the Talos versions whose configurations are compared
+// against golden files, "current" standing for an unset talos_version.
+var goldenContracts = []string{
+	"v0.8", "v0.9", "v0.10", "v0.11", "v0.12", "v0.13", "v0.14",
+	"v1.0", "v1.1", "v1.2", "current",
+}
+
+func TestGenConfigGolden(t *testing.T) {
+	secrets, err := os.ReadFile(filepath.Join("testdata", "gen_config", "secrets.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, contract := range goldenContracts {
+		contract := contract
+		t.Run(contract, func(t *testing.T) {
+			data := testGenConfigModel(string(secrets))
+			if contract != "current" {
+				data.TalosVersion = types.String{Value: contract}
+			}
+
+			if diags := generateConfig(context.Background(), data); diags.HasError() {
+				t.Fatalf("generateConfig: %v", diags)
+			}
+			if data.TalosConfig.Value == "" {
+				t.Error("talos_config is empty")
+			}
+
+			dir := filepath.Join("testdata", "gen_config", contract)
+			assertGolden(t, filepath.Join(dir, "controlplane.yaml"), data.ControlPlaneConfig.Value)
+			assertGolden(t, filepath.Join(dir, "worker.yaml"), data.WorkerConfig.Value)
+		})
+	}
+}
+
+// TestGenConfigGoldenOptions compares the configurations generated with the
+// options that are off by default against golden files, for the current
+// version contract.
+func TestGenConfigGoldenOptions(t *testing.T) {
+	secrets, err := os.ReadFile(filepath.Join("testdata", "gen_config", "secrets.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, modify := range map[string]func(data *GenConfigResourceModel){
+		"kubespan": func(data *GenConfigResourceModel) {
+			data.WithKubespan = types.Bool{Value: true}
+		},
+		"subnets": func(data *GenConfigResourceModel) {
+			data.PodSubnets = types.List{
+				ElemType: types.StringType,
+				Elems:    []attr.Value{types.String{Value: "10.128.0.0/16"}},
+			}
+			data.ServiceSubnets = types.List{
+				ElemType: types.StringType,
+				Elems:    []attr.Value{types.String{Value: "10.112.0.0/12"}, types.String{Value: "fd00:10:96::/112"}},
+			}
+		},
+		"no_cluster_discovery": func(data *GenConfigResourceModel) {
+			data.WithClusterDiscovery = types.Bool{Value: false}
+		},
+		"role_patches": func(data *GenConfigResourceModel) {
+			data.ConfigPatchControlPlane = types.List{
+				ElemType: types.StringType,
+				Elems: []attr.Value{
+					types.String{Value: `[{"op": "add", "path": "/cluster/allowSchedulingOnControlPlanes", "value": true}]`},
+				},
+			}
+			data.ConfigPatchWorker = types.List{
+				ElemType: types.StringType,
+				Elems: []attr.Value{
+					types.String{Value: "machine:\n  kubelet:\n    extraArgs:\n      node-labels: role=worker\n"},
+				},
+			}
+		},
+	} {
+		modify := modify
+		t.Run(name, func(t *testing.T) {
+			data := testGenConfigModel(string(secrets))
+			modify(data)
+
+			if diags := generateConfig(context.Background(), data); diags.HasError() {
+				t.Fatalf("generateConfig: %v", diags)
+			}
+
+			dir := filepath.Join("testdata", "gen_config", "options", name)
+			assertGolden(t, filepath.Join(dir, "controlplane.yaml"), data.ControlPlaneConfig.Value)
+			assertGolden(t, filepath.Join(dir, "worker.yaml"), data.WorkerConfig.Value)
+		})
+	}
+}
+
+func TestGenConfigDeterministic(t *testing.T) {
+	secrets, err := os.ReadFile(filepath.Join("testdata", "gen_config", "secrets.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := testGenConfigModel(string(secrets)), testGenConfigModel(string(secrets))
+	for _, data := range []*GenConfigResourceModel{first, second} {
+		if diags := generateConfig(context.Background(), data); diags.HasError() {
+			t.Fatalf("generateConfig: %v", diags)
+		}
+	}
+
+	if first.ControlPlaneConfig.Value != second.ControlPlaneConfig.Value {
+		t.Error("control plane configurations differ")
+	}
+	if first.WorkerConfig.Value != second.WorkerConfig.Value {
+		t.Error("worker configurations differ")
+	}
+}
+
+func TestParseSecretsBundle(t *testing.T) {
+	for name, in := range map[string]string{
+		"invalid":         "cluster: [",
+		"empty":           "",
+		"missing certs":   "cluster: {id: a, secret: b}\nsecrets: {bootstraptoken: c}\ntrustdinfo: {token: d}\n",
+		"missing cluster": "secrets: {bootstraptoken: c}\ntrustdinfo: {token: d}\ncerts: {}\n",
+	} {
+		if _, err := parseSecretsBundle(in); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGenConfigValidators(t *testing.T) {
+	for name, tt := range map[string]struct {
+		modify   func(data *GenConfigResourceModel)
+		errors   int
+		warnings int
+	}{
+		"defaults": {
+			modify: func(data *GenConfigResourceModel) {},
+		},
+		"kubespan without discovery": {
+			modify: func(data *GenConfigResourceModel) {
+				data.WithKubespan = types.Bool{Value: true}
+				data.WithClusterDiscovery = types.Bool{Value: false}
+			},
+			errors: 1,
+		},
+		"kubespan with old contract": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Value: "v0.12"}
+				data.KubernetesVersion = types.String{Value: "1.22.0"}
+				data.WithKubespan = types.Bool{Value: true}
+			},
+			errors: 2,
+		},
+		// Enabled by default
+		"default discovery with old contract": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Value: "v0.12"}
+				data.KubernetesVersion = types.String{Value: "1.22.0"}
+				data.WithClusterDiscovery = types.Bool{Null: true}
+			},
+			errors: 1,
+		},
+		"disabled discovery with old contract": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Value: "v0.12"}
+				data.KubernetesVersion = types.String{Value: "1.22.0"}
+				data.WithClusterDiscovery = types.Bool{Value: false}
+			},
+		},
+		"discovery with old contract": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Value: "v0.12"}
+				data.KubernetesVersion = types.String{Value: "1.22.0"}
+				data.WithClusterDiscovery = types.Bool{Value: true}
+			},
+			errors: 1,
+		},
+		"kubespan with default discovery": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Value: "v0.13"}
+				data.KubernetesVersion = types.String{Value: "1.22.0"}
+				data.WithKubespan = types.Bool{Value: true}
+				data.WithClusterDiscovery = types.Bool{Null: true}
+			},
+		},
+		"unsupported kubernetes version": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Value: "v1.0"}
+			},
+			warnings: 1,
+		},
+		"unsupported kubernetes version with current contract": {
+			modify: func(data *GenConfigResourceModel) {
+				data.KubernetesVersion = types.String{Value: "1.21.0"}
+			},
+			warnings: 1,
+		},
+		"default kubernetes version with old contract": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Value: "v1.1.2"}
+				data.KubernetesVersion = types.String{Null: true}
+			},
+			warnings: 1,
+		},
+		"unknown values": {
+			modify: func(data *GenConfigResourceModel) {
+				data.TalosVersion = types.String{Unknown: true}
+				data.WithKubespan = types.Bool{Unknown: true}
+			},
+		},
+	} {
+		data := testGenConfigModel("")
+		tt.modify(data)
+
+		var diags diag.Diagnostics
+		for _, v := range genConfigValidators {
+			v.(*genConfigValidator).validate(data, &diags)
+		}
+		if diags.ErrorsCount() != tt.errors || diags.WarningsCount() != tt.warnings {
+			t.Errorf("%s: expected %d errors and %d warnings, got %v", name, tt.errors, tt.warnings, diags)
+		}
+	}
+}
+
+func TestAccGenConfigResource_reformattedPatch(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGenConfigResourceConfig("yamlencode"),
+				Check:  resource.TestCheckResourceAttr("talos_gen_config.test", "id", "acc"),
+			},
+			// The same patches and secrets, formatted differently
+			{
+				Config:   testAccGenConfigResourceConfig("jsonencode"),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccGenConfigResource_update(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGenConfigResourceVersionConfig("1.25.4"),
+				Check:  testCheckResourceAttrContains("talos_gen_config.test", "worker_config", "kubelet:v1.25.4"),
+			},
+			{
+				Config: testAccGenConfigResourceVersionConfig("1.24.8"),
+				Check:  testCheckResourceAttrContains("talos_gen_config.test", "worker_config", "kubelet:v1.24.8"),
+			},
+		},
+	})
+}
+
+func TestAccGenConfigResource_updateGeneratedSecrets(t *testing.T) {
+	var secrets string
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccGenConfigResourceGeneratedSecretsConfig("1.25.4"),
+				Check: resource.TestCheckResourceAttrWith("talos_gen_config.test", "generated_secrets", func(value string) error {
+					if _, err := parseSecretsBundle(value); err != nil {
+						return err
+					}
+					secrets = value
+					return nil
+				}),
+			},
+			// The cluster keeps its secrets
+			{
+				Config: testAccGenConfigResourceGeneratedSecretsConfig("1.24.8"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testCheckResourceAttrContains("talos_gen_config.test", "worker_config", "kubelet:v1.24.8"),
+					resource.TestCheckResourceAttrWith("talos_gen_config.test", "generated_secrets", func(value string) error {
+						if value != secrets {
+							return fmt.Errorf("generated_secrets changed on update")
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func testAccGenConfigResourceGeneratedSecretsConfig(kubernetesVersion string) string {
+	return fmt.Sprintf(`
+resource "talos_gen_config" "test" {
+  cluster_name       = "acc"
+  cluster_endpoint   = "https://10.5.0.2:6443"
+  kubernetes_version = %q
+}
+`, kubernetesVersion)
+}
+
+func testAccGenConfigResourceVersionConfig(kubernetesVersion string) string {
+	return fmt.Sprintf(`
+resource "talos_gen_config" "test" {
+  cluster_name       = "acc"
+  cluster_endpoint   = "https://10.5.0.2:6443"
+  kubernetes_version = %q
+  secrets            = file("testdata/gen_config/secrets.yaml")
+}
+`, kubernetesVersion)
+}
+
+// testCheckResourceAttrContains checks that an attribute of a resource
+// contains substr.
+func testCheckResourceAttrContains(name, key, substr string) resource.TestCheckFunc {
+	return resource.TestCheckResourceAttrWith(name, key, func(value string) error {
+		if !strings.Contains(value, substr) {
+			return fmt.Errorf("%s does not contain %q", key, substr)
+		}
+		return nil
+	})
+}
+
+func testAccGenConfigResourceConfig(encode string) string {
+	return fmt.Sprintf(`
+resource "talos_gen_config" "test" {
+  cluster_name     = "acc"
+  cluster_endpoint = "https://10.5.0.2:6443"
+  config_patch = [%[1]s([{
+    op    = "add"
+    path  = "/machine/network/hostname"
+    value = "acc"
+  }])]
+  config_patch_worker = [%[1]s({
+    machine = { install = { wipe = true } }
+  })]
+  secrets = %[1]s(yamldecode(file("testdata/gen_config/secrets.yaml")))
+}
+`, encode)
+}
+
+// testGenConfigModel returns the configuration of a talos_gen_config resource
+// with the given secrets, as planned by Terraform.
+func testGenConfigModel(secrets string) *GenConfigResourceModel {
+	nullList := types.List{ElemType: types.StringType, Null: true}
+
+	return &GenConfigResourceModel{
+		ClusterName:       types.String{Value: "golden"},
+		ClusterEndpoint:   types.String{Value: "https://10.5.0.2:6443"},
+		KubernetesVersion: types.String{Value: "1.25.4"},
+		ConfigPatch: types.List{
+			ElemType: types.StringType,
+			Elems: []attr.Value{
+				types.String{Value: `[{"op": "add", "path": "/machine/network/hostname", "value": "golden"}]`},
+			},
+		},
+		ConfigPatchControlPlane: nullList,
+		ConfigPatchWorker:       nullList,
+		InstallDisk:             types.String{Value: "/dev/vda"},
+		InstallImage:            types.String{Value: "ghcr.io/siderolabs/installer:v1.2.3"},
+		AdditionalSans: types.List{
+			ElemType: types.StringType,
+			Elems:    []attr.Value{types.String{Value: "talos.example.com"}},
+		},
+		PodSubnets:           nullList,
+		ServiceSubnets:       nullList,
+		DnsDomain:            types.String{Value: "cluster.local"},
+		Persist:              types.Bool{Value: true},
+		WithClusterDiscovery: types.Bool{Value: true},
+		TalosVersion:         types.String{Null: true},
+		RegistryMirrors: types.Map{
+			ElemType: types.StringType,
+			Elems:    map[string]attr.Value{"docker.io": types.String{Value: "https://registry.example.com"}},
+		},
+		WithKubespan: types.Bool{Value: false},
+		Secrets:      types.String{Value: secrets},
+	}
+}
+
+// assertGolden compares got with the content of the golden file at path, or
+// writes it there if the -update flag is set.
+func assertGolden(t *testing.T, path, got string) {
+	t.Helper()
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s (run go test with -update to create it)", err)
+	}
+	if got == string(want) {
+		return
+	}
+
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var gotLine, wantLine string
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if gotLine != wantLine {
+			t.Errorf("%s:%d differs from the generated configuration (run go test with -update after checking the changes):\n-%s\n+%s", path, i+1, wantLine, gotLine)
+			return
+		}
+	}
+}
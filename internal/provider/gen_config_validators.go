@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/talos-systems/talos/pkg/machinery/config"
+	"github.com/talos-systems/talos/pkg/machinery/constants"
+)
+
+// kubernetesVersionRange is the range of Kubernetes minor versions supported
+// by a Talos version.
+type kubernetesVersionRange struct {
+	min, max uint64
+}
+
+// kubernetesVersionRanges is the Kubernetes compatibility matrix of the Talos
+// versions, by version contract. The current contract is the one of the
+// machinery package.
+var kubernetesVersionRanges = map[config.VersionContract]kubernetesVersionRange{
+	*config.TalosVersion0_8:  {19, 20},
+	*config.TalosVersion0_9:  {19, 20},
+	*config.TalosVersion0_10: {19, 21},
+	*config.TalosVersion0_11: {19, 21},
+	*config.TalosVersion0_12: {20, 22},
+	*config.TalosVersion0_13: {20, 22},
+	*config.TalosVersion0_14: {21, 23},
+	*config.TalosVersion1_0:  {21, 23},
+	*config.TalosVersion1_1:  {21, 24},
+	*config.TalosVersion1_2:  {22, 25},
+}
+
+// currentContract is the version contract of the machinery package.
+var currentContract = config.TalosVersion1_2
+
+// genConfigValidator validates the combination of the attributes of a
+// talos_gen_config resource with a function.
+type genConfigValidator struct {
+	description string
+	validate    func(data *GenConfigResourceModel, diags *diag.Diagnostics)
+}
+
+var _ resource.ConfigValidator = (*genConfigValidator)(nil)
+
+func (v *genConfigValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v *genConfigValidator) MarkdownDescription(ctx context.Context) string {
+	return v.description
+}
+
+func (v *genConfigValidator) ValidateResource(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var data *GenConfigResourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	v.validate(data, &resp.Diagnostics)
+}
+
+// genConfigValidators are the validators of the combinations of the
+// attributes of a talos_gen_config resource.
+var genConfigValidators = []resource.ConfigValidator{
+	&genConfigValidator{
+		description: "KubeSpan requires cluster discovery",
+		validate:    validateKubeSpanDiscovery,
+	},
+	&genConfigValidator{
+		description: "Features must be supported by `talos_version`",
+		validate:    validateContractFeatures,
+	},
+	&genConfigValidator{
+		description: "`kubernetes_version` should be supported by `talos_version`",
+		validate:    validateKubernetesVersion,
+	},
+}
+
+// validateKubeSpanDiscovery checks that cluster discovery is enabled when
+// KubeSpan is enabled, as KubeSpan finds its peers through it.
+func validateKubeSpanDiscovery(data *GenConfigResourceModel, diags *diag.Diagnostics) {
+	if data.WithKubespan.Unknown || !data.WithKubespan.Value || data.WithClusterDiscovery.Unknown {
+		return
+	}
+
+	if !clusterDiscoveryEnabled(data) {
+		diags.AddAttributeError(
+			path.Root("with_kubespan"),
+			"KubeSpan requires cluster discovery",
+			"KubeSpan discovers its peers through cluster discovery, set `with_cluster_discovery` to true or `with_kubespan` to false.",
+		)
+	}
+}
+
+// clusterDiscoveryEnabled tells whether the configuration is generated with
+// cluster discovery, which is enabled unless with_cluster_discovery is false.
+func clusterDiscoveryEnabled(data *GenConfigResourceModel) bool {
+	return data.WithClusterDiscovery.Null || data.WithClusterDiscovery.Value
+}
+
+// supportsKubeSpan tells whether a version contract supports KubeSpan and
+// cluster discovery, introduced in Talos v0.13. The machinery package has no
+// gate for them, so the bound is hand-coded.
+func supportsKubeSpan(contract *config.VersionContract) bool {
+	return contract.Greater(config.TalosVersion0_12)
+}
+
+// validateContractFeatures checks that the enabled features are supported by
+// the Talos version the configuration is generated for.
+func validateContractFeatures(data *GenConfigResourceModel, diags *diag.Diagnostics) {
+	contract, ok := genConfigContract(data)
+	if !ok || supportsKubeSpan(contract) {
+		return
+	}
+
+	if !data.WithKubespan.Unknown && data.WithKubespan.Value {
+		diags.AddAttributeError(
+			path.Root("with_kubespan"),
+			"KubeSpan is not supported by talos_version",
+			fmt.Sprintf("KubeSpan requires Talos v0.13 or later, but the configuration is generated for %s.", data.TalosVersion.Value),
+		)
+	}
+
+	if !data.WithClusterDiscovery.Unknown && clusterDiscoveryEnabled(data) {
+		diags.AddAttributeError(
+			path.Root("with_cluster_discovery"),
+			"Cluster discovery is not supported by talos_version",
+			fmt.Sprintf("Cluster discovery requires Talos v0.13 or later, but the configuration is generated for %s. "+
+				"It is enabled by default, set `with_cluster_discovery` to false.", data.TalosVersion.Value),
+		)
+	}
+}
+
+// validateKubernetesVersion warns when the Kubernetes version is outside the
+// range supported by the Talos version the configuration is generated for.
+func validateKubernetesVersion(data *GenConfigResourceModel, diags *diag.Diagnostics) {
+	contract, ok := genConfigContract(data)
+	if !ok || data.KubernetesVersion.Unknown {
+		return
+	}
+
+	supported, ok := kubernetesVersionRanges[*contract]
+	if !ok {
+		// Talos versions newer than the machinery package are not known
+		return
+	}
+
+	kubernetesVersion := constants.DefaultKubernetesVersion
+	if !data.KubernetesVersion.Null {
+		kubernetesVersion = data.KubernetesVersion.Value
+	}
+	version, err := semver.Parse(strings.TrimPrefix(kubernetesVersion, "v"))
+	if err != nil {
+		// Reported by the attribute validator
+		return
+	}
+
+	if version.Major != 1 || version.Minor < supported.min || version.Minor > supported.max {
+		diags.AddAttributeWarning(
+			path.Root("kubernetes_version"),
+			"Kubernetes version not supported by talos_version",
+			fmt.Sprintf("Talos v%d.%d supports Kubernetes 1.%d to 1.%d, but the configuration is generated for Kubernetes %s.",
+				contract.Major, contract.Minor, supported.min, supported.max, kubernetesVersion),
+		)
+	}
+}
+
+// genConfigContract returns the version contract of the configuration,
+// currentContract if `talos_version` is not set, or false if it is not known
+// yet or invalid.
+func genConfigContract(data *GenConfigResourceModel) (*config.VersionContract, bool) {
+	if data.TalosVersion.Unknown {
+		return nil, false
+	}
+	if data.TalosVersion.Null {
+		// config.TalosVersionCurrent is nil, which cannot be looked up
+		return currentContract, true
+	}
+
+	contract, err := config.ParseContractFromVersion(data.TalosVersion.Value)
+	if err != nil {
+		// Reported by the attribute validator
+		return nil, false
+	}
+	return contract, true
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccKubeconfigDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKubeconfigDataSourceConfig(talos),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_kubeconfig.test", "cluster_ca_certificate", "cluster-ca"),
+					resource.TestCheckResourceAttr("data.talos_kubeconfig.test", "client_certificate", "client-crt"),
+					resource.TestCheckResourceAttr("data.talos_kubeconfig.test", "client_key", "client-key"),
+					resource.TestCheckResourceAttrSet("data.talos_kubeconfig.test", "raw"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccKubeconfigDataSource_error(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("Kubeconfig", status.Error(codes.NotFound, "kubeconfig not found"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccKubeconfigDataSourceConfig(talos),
+				ExpectError: regexp.MustCompile("kubeconfig not found"),
+			},
+		},
+	})
+}
+
+func TestAccKubeconfigDataSource_permissionDenied(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("Kubeconfig", status.Error(codes.PermissionDenied, "not authorized"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccKubeconfigDataSourceConfig(talos),
+				ExpectError: regexp.MustCompile("Permission denied by the Talos API"),
+			},
+		},
+	})
+}
+
+func testAccKubeconfigDataSourceConfig(talos *fakeTalos) string {
+	return fmt.Sprintf(`
+data "talos_kubeconfig" "test" {%s}
+`, talos.testClientConfig())
+}
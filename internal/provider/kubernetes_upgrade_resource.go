@@ -0,0 +1,593 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"github.com/talos-systems/talos/pkg/machinery/constants"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_validator"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var _ resource.Resource = &KubernetesUpgradeResource{}
+
+func NewKubernetesUpgradeResource() resource.Resource {
+	return &KubernetesUpgradeResource{}
+}
+
+type KubernetesUpgradeResource struct{}
+
+type KubernetesUpgradeResourceModel struct {
+	ID                types.String `tfsdk:"id"`
+	Endpoint          types.String `tfsdk:"endpoint"`
+	Endpoints         types.List   `tfsdk:"endpoints"`
+	MachineCa         types.String `tfsdk:"machine_ca"`
+	MachineCrt        types.String `tfsdk:"machine_crt"`
+	MachineKey        types.String `tfsdk:"machine_key"`
+	ControlPlaneNodes types.List   `tfsdk:"control_plane_nodes"`
+	WorkerNodes       types.List   `tfsdk:"worker_nodes"`
+	KubernetesVersion types.String `tfsdk:"kubernetes_version"`
+	DryRun            types.Bool   `tfsdk:"dry_run"`
+	Timeout           types.String `tfsdk:"timeout"`
+}
+
+func (r *KubernetesUpgradeResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_kubernetes_upgrade"
+}
+
+func (r *KubernetesUpgradeResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	// The nodes are always given, so a new endpoint is taken as another cluster.
+	endpoint := clientAttributes["endpoint"]
+	endpoint.PlanModifiers = []tfsdk.AttributePlanModifier{
+		resource.RequiresReplace(),
+	}
+
+	return tfsdk.Schema{
+		MarkdownDescription: "Upgrade the Kubernetes control plane and kubelets of a Talos cluster, one component and one node at a time.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"endpoint": endpoint,
+			"control_plane_nodes": {
+				MarkdownDescription: "Control plane nodes of the cluster, reached through `endpoint`.",
+				Required:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"worker_nodes": {
+				MarkdownDescription: "Worker nodes of the cluster, reached through `endpoint`.",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"kubernetes_version": {
+				MarkdownDescription: "Kubernetes version to upgrade to (e.g. \"1.25.4\").",
+				Required:            true,
+				Type:                types.StringType,
+				Validators: []tfsdk.AttributeValidator{
+					attribute_validator.Semver(),
+				},
+			},
+			"dry_run": {
+				Computed:            true,
+				MarkdownDescription: "Report the planned steps as a warning without changing the cluster.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.Bool{Value: false}),
+				},
+				Type: types.BoolType,
+			},
+			"timeout": {
+				Computed:            true,
+				MarkdownDescription: "Maximum time to wait for each component to report the new version (default \"10m\").",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.String{Value: "10m"}),
+				},
+				Type: types.StringType,
+			},
+		}),
+	}, nil
+}
+
+func (r *KubernetesUpgradeResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		clientCredentialsValidator{adminRoles},
+	}
+}
+
+func (r *KubernetesUpgradeResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *KubernetesUpgradeResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.upgrade(ctx, data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a Talos Kubernetes upgrade resource")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *KubernetesUpgradeResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *KubernetesUpgradeResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *KubernetesUpgradeResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *KubernetesUpgradeResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.upgrade(ctx, data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *KubernetesUpgradeResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *KubernetesUpgradeResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// kubernetesUpgradeStep is a change of a component image, either in the
+// machine configuration of a node or, when node is empty, in the kube-proxy
+// DaemonSet.
+type kubernetesUpgradeStep struct {
+	component string
+	node      string
+	path      []string
+	image     string
+}
+
+// kubernetesUpgradeSteps returns the steps to upgrade a cluster to version,
+// in the same order as `talosctl upgrade-k8s`: first the control plane static
+// pods, then kube-proxy and finally the kubelets.
+func kubernetesUpgradeSteps(version string, controlPlaneNodes, workerNodes []string) []kubernetesUpgradeStep {
+	var steps []kubernetesUpgradeStep
+
+	for _, component := range []struct {
+		name  string
+		key   string
+		image string
+	}{
+		{"kube-apiserver", "apiServer", constants.KubernetesAPIServerImage},
+		{"kube-controller-manager", "controllerManager", constants.KubernetesControllerManagerImage},
+		{"kube-scheduler", "scheduler", constants.KubernetesSchedulerImage},
+		{"kube-proxy", "proxy", constants.KubeProxyImage},
+	} {
+		for _, node := range controlPlaneNodes {
+			steps = append(steps, kubernetesUpgradeStep{
+				component: component.name,
+				node:      node,
+				path:      []string{"cluster", component.key, "image"},
+				image:     fmt.Sprintf("%s:v%s", component.image, version),
+			})
+		}
+	}
+
+	steps = append(steps, kubernetesUpgradeStep{
+		component: "kube-proxy",
+		image:     fmt.Sprintf("%s:v%s", constants.KubeProxyImage, version),
+	})
+
+	nodes := append([]string{}, controlPlaneNodes...)
+	nodes = append(nodes, workerNodes...)
+	for _, node := range nodes {
+		steps = append(steps, kubernetesUpgradeStep{
+			component: "kubelet",
+			node:      node,
+			path:      []string{"machine", "kubelet", "image"},
+			image:     fmt.Sprintf("%s:v%s", constants.KubeletImage, version),
+		})
+	}
+
+	return steps
+}
+
+// upgrade patches the machine configuration of the nodes step by step,
+// waiting for each component to report the new version before moving on. In
+// dry run mode, the pending steps are only reported as a warning.
+func (r *KubernetesUpgradeResource) upgrade(ctx context.Context, data *KubernetesUpgradeResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	version := strings.TrimPrefix(data.KubernetesVersion.Value, "v")
+
+	timeout, err := time.ParseDuration(data.Timeout.Value)
+	if err != nil {
+		diags.AddError(
+			"Error parsing timeout",
+			err.Error(),
+		)
+		return diags
+	}
+
+	var controlPlaneNodes []string
+	diags.Append(data.ControlPlaneNodes.ElementsAs(ctx, &controlPlaneNodes, false)...)
+	var workerNodes []string
+	diags.Append(data.WorkerNodes.ElementsAs(ctx, &workerNodes, false)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	conn, connDiags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	diags.Append(connDiags...)
+	if diags.HasError() {
+		return diags
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	k8s, err := kubernetesClient(ctx, client)
+	if err != nil {
+		diags.AddError(
+			"Error creating Kubernetes client",
+			err.Error(),
+		)
+		return diags
+	}
+
+	var planned []string
+
+	for _, step := range kubernetesUpgradeSteps(version, controlPlaneNodes, workerNodes) {
+		target := "kube-proxy DaemonSet"
+		if step.node != "" {
+			target = fmt.Sprintf("node %s", step.node)
+		}
+
+		previous, apply, err := r.prepareStep(ctx, client, k8s, step)
+		if err != nil {
+			diags.AddError(
+				fmt.Sprintf("Error reading %s image of %s", step.component, target),
+				err.Error(),
+			)
+			return diags
+		}
+
+		if previous == step.image {
+			tflog.Debug(ctx, "component already up to date", map[string]interface{}{"component": step.component, "target": target})
+			continue
+		}
+
+		if data.DryRun.Value {
+			planned = append(planned, fmt.Sprintf("%s on %s: %q -> %q", step.component, target, previous, step.image))
+			continue
+		}
+
+		tflog.Info(ctx, "upgrading Kubernetes component", map[string]interface{}{"component": step.component, "target": target, "image": step.image})
+
+		if err := apply(); err != nil {
+			diags.AddError(
+				fmt.Sprintf("Error updating %s image of %s", step.component, target),
+				err.Error(),
+			)
+			return diags
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = r.waitForStep(waitCtx, client, k8s, step, version)
+		cancel()
+		if err != nil {
+			diags.AddError(
+				fmt.Sprintf("Error waiting for %s on %s", step.component, target),
+				err.Error(),
+			)
+			return diags
+		}
+	}
+
+	if data.DryRun.Value {
+		if len(planned) == 0 {
+			planned = append(planned, "no changes, all components already run the requested version")
+		}
+		diags.AddWarning(
+			"Kubernetes upgrade plan (dry run)",
+			strings.Join(planned, "\n"),
+		)
+	}
+
+	return diags
+}
+
+// prepareStep returns the current image of the component changed by step,
+// and a function that sets it to the new one.
+func (r *KubernetesUpgradeResource) prepareStep(ctx context.Context, client machine.MachineServiceClient, k8s *kubernetes.Clientset, step kubernetesUpgradeStep) (string, func() error, error) {
+	if step.node == "" {
+		daemonSets := k8s.AppsV1().DaemonSets("kube-system")
+
+		ds, err := daemonSets.Get(ctx, "kube-proxy", metav1.GetOptions{})
+		if err != nil {
+			// Clusters without kube-proxy are left untouched.
+			if apierrors.IsNotFound(err) {
+				return step.image, nil, nil
+			}
+			return "", nil, err
+		}
+
+		var previous string
+		for _, container := range ds.Spec.Template.Spec.Containers {
+			if container.Name == "kube-proxy" {
+				previous = container.Image
+			}
+		}
+
+		return previous, func() error {
+			patch := fmt.Sprintf(`{"spec":{"template":{"spec":{"containers":[{"name":"kube-proxy","image":%q}]}}}}`, step.image)
+			_, err := daemonSets.Patch(ctx, "kube-proxy", k8stypes.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+			return err
+		}, nil
+	}
+
+	nodeCtx := nodeContext(ctx, step.node)
+
+	config, err := readMachineConfig(nodeCtx, client)
+	if err != nil {
+		return "", nil, err
+	}
+
+	previous := setYAMLValue(config, step.image, step.path...)
+
+	return previous, func() error {
+		return applyMachineConfig(nodeCtx, client, config)
+	}, nil
+}
+
+// waitForStep waits for the component upgraded by step to report the new
+// version.
+func (r *KubernetesUpgradeResource) waitForStep(ctx context.Context, client machine.MachineServiceClient, k8s *kubernetes.Clientset, step kubernetesUpgradeStep, version string) error {
+	switch {
+	case step.node == "":
+		return waitUntil(ctx, defaultPollInterval, func(ctx context.Context) (bool, error) {
+			return daemonSetRolledOut(ctx, k8s, "kube-system", "kube-proxy")
+		})
+	case step.component == "kube-proxy":
+		// The kube-proxy configuration of the control plane nodes is only
+		// used to render the DaemonSet manifest.
+		return nil
+	}
+
+	nodeName, err := nodeHostname(nodeContext(ctx, step.node), client)
+	if err != nil {
+		return err
+	}
+
+	return waitUntil(ctx, defaultPollInterval, func(ctx context.Context) (bool, error) {
+		if step.component == "kubelet" {
+			return kubeletReady(ctx, k8s, nodeName, "v"+version)
+		}
+		return staticPodReady(ctx, k8s, step.component, nodeName, step.image)
+	})
+}
+
+// readFile reads the file at path from the node addressed by ctx, failing if
+// it is larger than maxSize bytes unless maxSize is noSizeLimit.
+func readFile(ctx context.Context, client machine.MachineServiceClient, path string, maxSize int64) ([]byte, error) {
+	// Stops the stream of a file that is too large
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.Read(ctx, &machine.ReadRequest{Path: path})
+	if err != nil {
+		return nil, err
+	}
+
+	return readAllStream(stream, maxSize)
+}
+
+// readMachineConfig reads the current machine configuration of the node
+// addressed by ctx.
+func readMachineConfig(ctx context.Context, client machine.MachineServiceClient) (*yaml.Node, error) {
+	content, err := readFile(ctx, client, constants.ConfigPath, noSizeLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	var config yaml.Node
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	if config.Kind != yaml.DocumentNode || len(config.Content) == 0 || config.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid machine configuration in %s", constants.ConfigPath)
+	}
+
+	return &config, nil
+}
+
+// applyMachineConfig applies config to the node addressed by ctx, without
+// rebooting it.
+func applyMachineConfig(ctx context.Context, client machine.MachineServiceClient, config *yaml.Node) error {
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.ApplyConfiguration(ctx, &machine.ApplyConfigurationRequest{
+		Data: content,
+		Mode: machine.ApplyConfigurationRequest_NO_REBOOT,
+	})
+	return err
+}
+
+// setYAMLValue sets the scalar at path in the YAML document doc to value,
+// creating the missing mappings along the way, and returns its previous
+// value.
+func setYAMLValue(doc *yaml.Node, value string, path ...string) string {
+	node := doc.Content[0]
+
+	for i, key := range path {
+		last := i == len(path)-1
+
+		var child *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key {
+				child = node.Content[j+1]
+				break
+			}
+		}
+
+		if child == nil {
+			child = &yaml.Node{}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				child,
+			)
+		}
+
+		if !last && child.Kind != yaml.MappingNode {
+			*child = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+
+		node = child
+	}
+
+	previous := node.Value
+	*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+
+	return previous
+}
+
+// nodeHostname returns the hostname of the node addressed by ctx, which Talos
+// uses as Kubernetes node name.
+func nodeHostname(ctx context.Context, client machine.MachineServiceClient) (string, error) {
+	resp, err := client.Hostname(ctx, &emptypb.Empty{})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Messages) == 0 {
+		return "", fmt.Errorf("empty hostname response")
+	}
+	return strings.ToLower(resp.Messages[0].Hostname), nil
+}
+
+// kubernetesClient returns a Kubernetes client built from the admin
+// kubeconfig of the cluster.
+func kubernetesClient(ctx context.Context, client machine.MachineServiceClient) (*kubernetes.Clientset, error) {
+	kubeconfig, err := downloadKubeconfig(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
+
+// staticPodReady reports whether the mirror pod of the component static pod
+// on nodeName runs image and is ready.
+func staticPodReady(ctx context.Context, k8s *kubernetes.Clientset, component, nodeName, image string) (bool, error) {
+	pod, err := k8s.CoreV1().Pods("kube-system").Get(ctx, component+"-"+nodeName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name == component && container.Image != image {
+			return false, nil
+		}
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue, nil
+		}
+	}
+
+	return false, nil
+}
+
+// kubeletReady reports whether the kubelet of nodeName runs version and the
+// node is ready.
+func kubeletReady(ctx context.Context, k8s *kubernetes.Clientset, nodeName, version string) (bool, error) {
+	node, err := k8s.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	if node.Status.NodeInfo.KubeletVersion != version {
+		return false, nil
+	}
+
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue, nil
+		}
+	}
+
+	return false, nil
+}
+
+// daemonSetRolledOut reports whether all the pods of the DaemonSet are
+// updated and available.
+func daemonSetRolledOut(ctx context.Context, k8s *kubernetes.Clientset, namespace, name string) (bool, error) {
+	ds, err := k8s.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return ds.Status.ObservedGeneration >= ds.Generation &&
+		ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled &&
+		ds.Status.NumberAvailable == ds.Status.DesiredNumberScheduled, nil
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"github.com/talos-systems/talos/pkg/machinery/constants"
+)
+
+func TestKubernetesUpgradePrepareStep(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetFile(constants.ConfigPath, []byte(`version: v1alpha1
+machine:
+  type: controlplane
+cluster:
+  apiServer:
+    image: registry.k8s.io/kube-apiserver:v1.25.2
+`))
+
+	conn, diags := dialTalos(
+		context.Background(),
+		types.String{Value: talos.Endpoint},
+		types.List{ElemType: types.StringType, Null: true},
+		types.String{Value: talos.CA},
+		types.String{Value: talos.Crt},
+		types.String{Value: talos.Key},
+	)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	r := &KubernetesUpgradeResource{}
+	for _, tt := range []struct {
+		step     kubernetesUpgradeStep
+		previous string
+		expected string
+	}{
+		{
+			kubernetesUpgradeStep{
+				component: "kube-apiserver",
+				node:      "10.5.0.2",
+				path:      []string{"cluster", "apiServer", "image"},
+				image:     "registry.k8s.io/kube-apiserver:v1.25.3",
+			},
+			"registry.k8s.io/kube-apiserver:v1.25.2",
+			"    apiServer:\n        image: registry.k8s.io/kube-apiserver:v1.25.3\n",
+		},
+		// Missing mappings are created
+		{
+			kubernetesUpgradeStep{
+				component: "kubelet",
+				node:      "10.5.0.2",
+				path:      []string{"machine", "kubelet", "image"},
+				image:     "ghcr.io/siderolabs/kubelet:v1.25.3",
+			},
+			"",
+			"    kubelet:\n        image: ghcr.io/siderolabs/kubelet:v1.25.3\n",
+		},
+	} {
+		previous, apply, err := r.prepareStep(context.Background(), client, nil, tt.step)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.step.component, err)
+		}
+		if previous != tt.previous {
+			t.Errorf("%s: expected previous image %q, got %q", tt.step.component, tt.previous, previous)
+		}
+		if err := apply(); err != nil {
+			t.Fatalf("%s: %s", tt.step.component, err)
+		}
+
+		configs := talos.AppliedConfigs()
+		if config := string(configs[len(configs)-1]); !strings.Contains(config, tt.expected) {
+			t.Errorf("%s: expected the applied configuration to contain %q, got:\n%s", tt.step.component, tt.expected, config)
+		}
+	}
+}
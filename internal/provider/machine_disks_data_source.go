@@ -0,0 +1,270 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var _ datasource.DataSource = &MachineDisksDataSource{}
+
+func NewMachineDisksDataSource() datasource.DataSource {
+	return &MachineDisksDataSource{}
+}
+
+type MachineDisksDataSource struct{}
+
+type MachineDisksDataSourceModel struct {
+	ID         types.String             `tfsdk:"id"`
+	Endpoint   types.String             `tfsdk:"endpoint"`
+	Endpoints  types.List               `tfsdk:"endpoints"`
+	MachineCa  types.String             `tfsdk:"machine_ca"`
+	MachineCrt types.String             `tfsdk:"machine_crt"`
+	MachineKey types.String             `tfsdk:"machine_key"`
+	Node       types.String             `tfsdk:"node"`
+	Filter     *MachineDisksFilterModel `tfsdk:"filter"`
+	Disks      []MachineDiskModel       `tfsdk:"disks"`
+}
+
+type MachineDisksFilterModel struct {
+	Type    types.String `tfsdk:"type"`
+	Model   types.String `tfsdk:"model"`
+	MinSize types.Int64  `tfsdk:"min_size"`
+	MaxSize types.Int64  `tfsdk:"max_size"`
+}
+
+type MachineDiskModel struct {
+	DeviceName types.String `tfsdk:"device_name"`
+	Model      types.String `tfsdk:"model"`
+	Serial     types.String `tfsdk:"serial"`
+	Size       types.Int64  `tfsdk:"size"`
+	Type       types.String `tfsdk:"type"`
+	Wwid       types.String `tfsdk:"wwid"`
+	BusPath    types.String `tfsdk:"bus_path"`
+}
+
+func (d *MachineDisksDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_machine_disks"
+}
+
+func (d *MachineDisksDataSource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	attributes := withClientAttributes(map[string]tfsdk.Attribute{
+		"id": {
+			Computed:            true,
+			MarkdownDescription: "Identifier, set to `endpoint`.",
+			Type:                types.StringType,
+		},
+		"node": {
+			MarkdownDescription: "Node to query through `endpoint` (defaults to the node at `endpoint`). Not supported in maintenance mode.",
+			Optional:            true,
+			Type:                types.StringType,
+		},
+		"filter": {
+			Attributes: tfsdk.SingleNestedAttributes(map[string]tfsdk.Attribute{
+				"type": {
+					MarkdownDescription: "Only return disks of this type (`SSD`, `HDD`, `NVME` or `SD`).",
+					Optional:            true,
+					Type:                types.StringType,
+				},
+				"model": {
+					MarkdownDescription: "Only return disks whose model matches this glob pattern (e.g. `Samsung*`).",
+					Optional:            true,
+					Type:                types.StringType,
+				},
+				"min_size": {
+					MarkdownDescription: "Only return disks of at least this size, in bytes.",
+					Optional:            true,
+					Type:                types.Int64Type,
+				},
+				"max_size": {
+					MarkdownDescription: "Only return disks of at most this size, in bytes.",
+					Optional:            true,
+					Type:                types.Int64Type,
+				},
+			}),
+			MarkdownDescription: "Criteria the returned disks must match.",
+			Optional:            true,
+		},
+		"disks": {
+			Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+				"device_name": {
+					Computed:            true,
+					MarkdownDescription: "Device path of the disk (e.g. `/dev/sda`).",
+					Type:                types.StringType,
+				},
+				"model": {
+					Computed:            true,
+					MarkdownDescription: "Model of the disk.",
+					Type:                types.StringType,
+				},
+				"serial": {
+					Computed:            true,
+					MarkdownDescription: "Serial number of the disk.",
+					Type:                types.StringType,
+				},
+				"size": {
+					Computed:            true,
+					MarkdownDescription: "Size of the disk, in bytes.",
+					Type:                types.Int64Type,
+				},
+				"type": {
+					Computed:            true,
+					MarkdownDescription: "Type of the disk (`SSD`, `HDD`, `NVME`, `SD` or `UNKNOWN`).",
+					Type:                types.StringType,
+				},
+				"wwid": {
+					Computed:            true,
+					MarkdownDescription: "World Wide Identifier of the disk.",
+					Type:                types.StringType,
+				},
+				"bus_path": {
+					Computed:            true,
+					MarkdownDescription: "Bus path of the disk.",
+					Type:                types.StringType,
+				},
+			}),
+			Computed:            true,
+			MarkdownDescription: "Disks of the node matching `filter`.",
+		},
+	})
+
+	// Nodes in maintenance mode have no credentials yet.
+	for _, name := range []string{"machine_ca", "machine_crt", "machine_key"} {
+		attribute := attributes[name]
+		attribute.Required = false
+		attribute.Optional = true
+		attribute.MarkdownDescription += " Leave unset to query a node in maintenance mode."
+		attributes[name] = attribute
+	}
+
+	return tfsdk.Schema{
+		MarkdownDescription: "List the disks of a Talos node, for example to choose the install disk.",
+
+		Attributes: attributes,
+	}, nil
+}
+
+func (d *MachineDisksDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		clientCredentialsValidator{readerRoles},
+	}
+}
+
+func (d *MachineDisksDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data *MachineDisksDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var diskType storage.Disk_DiskType
+	if data.Filter != nil && !data.Filter.Type.Null {
+		value, ok := storage.Disk_DiskType_value[strings.ToUpper(data.Filter.Type.Value)]
+		if !ok {
+			resp.Diagnostics.AddError(
+				"Invalid disk type",
+				fmt.Sprintf("Unknown disk type %q.", data.Filter.Type.Value),
+			)
+			return
+		}
+		diskType = storage.Disk_DiskType(value)
+	}
+
+	var conn *grpc.ClientConn
+	if data.MachineCa.Null && data.MachineCrt.Null && data.MachineKey.Null {
+		endpoints, diags := clientEndpoints(ctx, data.Endpoint, data.Endpoints)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		var err error
+		conn, err = dialTalosMaintenance(ctx, endpoints)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error creating gRPC connection",
+				err.Error(),
+			)
+			return
+		}
+	} else {
+		var diags diag.Diagnostics
+		conn, diags = dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+		resp.Diagnostics.Append(diags...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+	defer conn.Close()
+	client := storage.NewStorageServiceClient(conn)
+
+	disksResp, err := client.Disks(nodeContext(ctx, data.Node.Value), &emptypb.Empty{})
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error listing disks",
+			err.Error(),
+		)
+		return
+	}
+
+	data.Disks = nil
+	for _, msg := range disksResp.Messages {
+		for _, disk := range msg.Disks {
+			if data.Filter != nil && !data.Filter.matches(disk, diskType) {
+				continue
+			}
+
+			data.Disks = append(data.Disks, MachineDiskModel{
+				DeviceName: types.String{Value: disk.DeviceName},
+				Model:      types.String{Value: disk.Model},
+				Serial:     types.String{Value: disk.Serial},
+				Size:       types.Int64{Value: int64(disk.Size)},
+				Type:       types.String{Value: disk.Type.String()},
+				Wwid:       types.String{Value: disk.Wwid},
+				BusPath:    types.String{Value: disk.BusPath},
+			})
+		}
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "read a Talos machine disks data source")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// matches reports whether disk satisfies the filter. diskType is the parsed
+// value of the type filter.
+func (f *MachineDisksFilterModel) matches(disk *storage.Disk, diskType storage.Disk_DiskType) bool {
+	if !f.Type.Null && disk.Type != diskType {
+		return false
+	}
+	if !f.Model.Null {
+		if ok, _ := path.Match(f.Model.Value, disk.Model); !ok {
+			return false
+		}
+	}
+	if !f.MinSize.Null && int64(disk.Size) < f.MinSize.Value {
+		return false
+	}
+	if !f.MaxSize.Null && int64(disk.Size) > f.MaxSize.Value {
+		return false
+	}
+	return true
+}
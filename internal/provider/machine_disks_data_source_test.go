@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/talos-systems/talos/pkg/machinery/api/storage"
+)
+
+func TestAccMachineDisksDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetDisks(
+		&storage.Disk{DeviceName: "/dev/nvme0n1", Model: "Samsung SSD 980", Serial: "S64DNF0R", Size: 500107862016, Type: storage.Disk_NVME, Wwid: "eui.002538b411b2ee8a", BusPath: "/pci0000:00/0000:00:1d.0/0000:3d:00.0/nvme"},
+		&storage.Disk{DeviceName: "/dev/sda", Model: "ST4000DM004", Serial: "ZFN0GQ9X", Size: 4000787030016, Type: storage.Disk_HDD, BusPath: "/pci0000:00/0000:00:17.0/ata1/host0/target0:0:0/0:0:0:0"},
+	)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineDisksDataSourceConfig(talos, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.#", "2"),
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.0.device_name", "/dev/nvme0n1"),
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.0.type", "NVME"),
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.0.size", "500107862016"),
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.0.wwid", "eui.002538b411b2ee8a"),
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.1.model", "ST4000DM004"),
+				),
+			},
+			{
+				Config: testAccMachineDisksDataSourceConfig(talos, `
+  filter = {
+    type     = "hdd"
+    min_size = 1000000000000
+  }
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.#", "1"),
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.0.device_name", "/dev/sda"),
+				),
+			},
+			{
+				Config: testAccMachineDisksDataSourceConfig(talos, `
+  filter = {
+    model = "Samsung*"
+  }
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.#", "1"),
+					resource.TestCheckResourceAttr("data.talos_machine_disks.test", "disks.0.serial", "S64DNF0R"),
+				),
+			},
+			{
+				Config: testAccMachineDisksDataSourceConfig(talos, `
+  filter = {
+    type = "tape"
+  }
+`),
+				ExpectError: regexp.MustCompile("Invalid disk type"),
+			},
+		},
+	})
+}
+
+func testAccMachineDisksDataSourceConfig(talos *fakeTalos, filter string) string {
+	return fmt.Sprintf(`
+data "talos_machine_disks" "test" {%s%s}
+`, talos.testClientConfig(), filter)
+}
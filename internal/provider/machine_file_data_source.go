@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+)
+
+var _ datasource.DataSource = &MachineFileDataSource{}
+
+func NewMachineFileDataSource() datasource.DataSource {
+	return &MachineFileDataSource{}
+}
+
+type MachineFileDataSource struct{}
+
+type MachineFileDataSourceModel struct {
+	ID               types.String `tfsdk:"id"`
+	Endpoint         types.String `tfsdk:"endpoint"`
+	Endpoints        types.List   `tfsdk:"endpoints"`
+	MachineCa        types.String `tfsdk:"machine_ca"`
+	MachineCrt       types.String `tfsdk:"machine_crt"`
+	MachineKey       types.String `tfsdk:"machine_key"`
+	Node             types.String `tfsdk:"node"`
+	Path             types.String `tfsdk:"path"`
+	MaxSize          types.Int64  `tfsdk:"max_size"`
+	Base64           types.Bool   `tfsdk:"base64"`
+	Sensitive        types.Bool   `tfsdk:"sensitive"`
+	Content          types.String `tfsdk:"content"`
+	SensitiveContent types.String `tfsdk:"sensitive_content"`
+	Size             types.Int64  `tfsdk:"size"`
+	Sha256           types.String `tfsdk:"sha256"`
+}
+
+// defaultMaxFileSize is the default maximum size of the files read by the
+// talos_machine_file data source.
+const defaultMaxFileSize = 1024 * 1024
+
+func (d *MachineFileDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_machine_file"
+}
+
+func (d *MachineFileDataSource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Read a file from a Talos node.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				Type:                types.StringType,
+			},
+			"node": {
+				MarkdownDescription: "Node to read the file from, reached through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"path": {
+				MarkdownDescription: "Path of the file on the node.",
+				Required:            true,
+				Type:                types.StringType,
+			},
+			"max_size": {
+				MarkdownDescription: fmt.Sprintf("Maximum size of the file, in bytes (default %d).", defaultMaxFileSize),
+				Optional:            true,
+				Type:                types.Int64Type,
+			},
+			"base64": {
+				MarkdownDescription: "Return the content encoded in base64, for binary files.",
+				Optional:            true,
+				Type:                types.BoolType,
+			},
+			"sensitive": {
+				MarkdownDescription: "Return the content in `sensitive_content` instead of `content`, to hide it from the plan output.",
+				Optional:            true,
+				Type:                types.BoolType,
+			},
+			"content": {
+				Computed:            true,
+				MarkdownDescription: "Content of the file, unless `sensitive` is set.",
+				Type:                types.StringType,
+			},
+			"sensitive_content": {
+				Computed:            true,
+				MarkdownDescription: "Content of the file, if `sensitive` is set.",
+				Sensitive:           true,
+				Type:                types.StringType,
+			},
+			"size": {
+				Computed:            true,
+				MarkdownDescription: "Size of the file, in bytes.",
+				Type:                types.Int64Type,
+			},
+			"sha256": {
+				Computed:            true,
+				MarkdownDescription: "SHA-256 checksum of the file.",
+				Type:                types.StringType,
+			},
+		}),
+	}, nil
+}
+
+func (d *MachineFileDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		clientCredentialsValidator{adminRoles},
+	}
+}
+
+func (d *MachineFileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data *MachineFileDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	maxSize := int64(defaultMaxFileSize)
+	if !data.MaxSize.Null {
+		maxSize = data.MaxSize.Value
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	content, err := readFile(nodeContext(ctx, data.Node.Value), client, data.Path.Value, maxSize)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Error reading %s", data.Path.Value),
+			err.Error(),
+		)
+		return
+	}
+
+	sum := sha256.Sum256(content)
+	data.Size = types.Int64{Value: int64(len(content))}
+	data.Sha256 = types.String{Value: hex.EncodeToString(sum[:])}
+
+	value := string(content)
+	if data.Base64.Value {
+		value = base64.StdEncoding.EncodeToString(content)
+	}
+	if data.Sensitive.Value {
+		data.Content = types.String{Null: true}
+		data.SensitiveContent = types.String{Value: value}
+	} else {
+		data.Content = types.String{Value: value}
+		data.SensitiveContent = types.String{Null: true}
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "read a Talos machine file data source")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
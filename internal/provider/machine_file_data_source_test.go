@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccMachineFileDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetFile("/etc/os-release", []byte("NAME=\"Talos\"\n"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+data "talos_machine_file" "test" {%s
+  path = "/etc/os-release"
+}
+`, talos.testClientConfig()),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_machine_file.test", "content", "NAME=\"Talos\"\n"),
+					resource.TestCheckResourceAttr("data.talos_machine_file.test", "size", "13"),
+					resource.TestCheckResourceAttr("data.talos_machine_file.test", "sha256", "3bbc0f5432caa1e8cd0e29b0f4b2f42d1ac58ab24645b1587ef0ebd1be276d86"),
+					resource.TestCheckNoResourceAttr("data.talos_machine_file.test", "sensitive_content"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(`
+data "talos_machine_file" "test" {%s
+  path      = "/etc/os-release"
+  base64    = true
+  sensitive = true
+}
+`, talos.testClientConfig()),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("data.talos_machine_file.test", "content"),
+					resource.TestCheckResourceAttr("data.talos_machine_file.test", "sensitive_content", "TkFNRT0iVGFsb3MiCg=="),
+				),
+			},
+		},
+	})
+}
+
+func TestAccMachineFileDataSource_tooLarge(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetFile("/var/log/large", make([]byte, 2048))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+data "talos_machine_file" "test" {%s
+  path     = "/var/log/large"
+  max_size = 1024
+}
+`, talos.testClientConfig()),
+				ExpectError: regexp.MustCompile("larger than 1024 bytes"),
+			},
+		},
+	})
+}
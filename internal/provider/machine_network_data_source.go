@@ -0,0 +1,417 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	cosiv1alpha1 "github.com/cosi-project/runtime/api/v1alpha1"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/nethelpers"
+	"github.com/talos-systems/talos/pkg/machinery/resources/network"
+)
+
+var _ datasource.DataSource = &MachineNetworkDataSource{}
+
+func NewMachineNetworkDataSource() datasource.DataSource {
+	return &MachineNetworkDataSource{}
+}
+
+type MachineNetworkDataSource struct{}
+
+type MachineNetworkDataSourceModel struct {
+	ID         types.String            `tfsdk:"id"`
+	Endpoint   types.String            `tfsdk:"endpoint"`
+	Endpoints  types.List              `tfsdk:"endpoints"`
+	MachineCa  types.String            `tfsdk:"machine_ca"`
+	MachineCrt types.String            `tfsdk:"machine_crt"`
+	MachineKey types.String            `tfsdk:"machine_key"`
+	Node       types.String            `tfsdk:"node"`
+	Links      types.List              `tfsdk:"links"`
+	Family     types.String            `tfsdk:"family"`
+	Hostname   types.String            `tfsdk:"hostname"`
+	Domainname types.String            `tfsdk:"domainname"`
+	Interfaces []MachineInterfaceModel `tfsdk:"interfaces"`
+	Addresses  []MachineAddressModel   `tfsdk:"addresses"`
+	Routes     []MachineRouteModel     `tfsdk:"routes"`
+}
+
+type MachineInterfaceModel struct {
+	Name             types.String `tfsdk:"name"`
+	Index            types.Int64  `tfsdk:"index"`
+	Type             types.String `tfsdk:"type"`
+	Kind             types.String `tfsdk:"kind"`
+	HardwareAddr     types.String `tfsdk:"hardware_addr"`
+	Mtu              types.Int64  `tfsdk:"mtu"`
+	OperationalState types.String `tfsdk:"operational_state"`
+	LinkState        types.Bool   `tfsdk:"link_state"`
+}
+
+type MachineAddressModel struct {
+	LinkName types.String `tfsdk:"link_name"`
+	Address  types.String `tfsdk:"address"`
+	Family   types.String `tfsdk:"family"`
+	Scope    types.String `tfsdk:"scope"`
+}
+
+type MachineRouteModel struct {
+	OutLinkName types.String `tfsdk:"out_link_name"`
+	Family      types.String `tfsdk:"family"`
+	Destination types.String `tfsdk:"destination"`
+	Gateway     types.String `tfsdk:"gateway"`
+	Source      types.String `tfsdk:"source"`
+	Table       types.String `tfsdk:"table"`
+	Priority    types.Int64  `tfsdk:"priority"`
+	Scope       types.String `tfsdk:"scope"`
+}
+
+// addrString formats an address or a prefix of a network resource spec,
+// which are zero when not set (e.g. the gateway of a link route).
+func addrString(addr interface {
+	IsZero() bool
+	String() string
+}) string {
+	if addr.IsZero() {
+		return ""
+	}
+	return addr.String()
+}
+
+func (d *MachineNetworkDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_machine_network"
+}
+
+func (d *MachineNetworkDataSource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Read the network interfaces, addresses and routes of a Talos node, as configured by Talos.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				Type:                types.StringType,
+			},
+			"node": {
+				MarkdownDescription: "Node to query through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"links": {
+				MarkdownDescription: "Only return the interfaces, addresses and routes of these links (e.g. `eth0`).",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"family": {
+				MarkdownDescription: "Only return the addresses and routes of this family (`inet4` or `inet6`).",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"hostname": {
+				Computed:            true,
+				MarkdownDescription: "Hostname of the node.",
+				Type:                types.StringType,
+			},
+			"domainname": {
+				Computed:            true,
+				MarkdownDescription: "Domain name of the node.",
+				Type:                types.StringType,
+			},
+			"interfaces": {
+				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+					"name": {
+						Computed:            true,
+						MarkdownDescription: "Name of the link.",
+						Type:                types.StringType,
+					},
+					"index": {
+						Computed:            true,
+						MarkdownDescription: "Index of the link.",
+						Type:                types.Int64Type,
+					},
+					"type": {
+						Computed:            true,
+						MarkdownDescription: "Type of the link (e.g. `ether`, `loopback`).",
+						Type:                types.StringType,
+					},
+					"kind": {
+						Computed:            true,
+						MarkdownDescription: "Kind of the link (e.g. `bond`, `vlan`), empty for physical links.",
+						Type:                types.StringType,
+					},
+					"hardware_addr": {
+						Computed:            true,
+						MarkdownDescription: "MAC address of the link.",
+						Type:                types.StringType,
+					},
+					"mtu": {
+						Computed:            true,
+						MarkdownDescription: "MTU of the link.",
+						Type:                types.Int64Type,
+					},
+					"operational_state": {
+						Computed:            true,
+						MarkdownDescription: "Operational state of the link (e.g. `up`, `down`).",
+						Type:                types.StringType,
+					},
+					"link_state": {
+						Computed:            true,
+						MarkdownDescription: "Whether the link has a carrier.",
+						Type:                types.BoolType,
+					},
+				}),
+				Computed:            true,
+				MarkdownDescription: "Network interfaces of the node.",
+			},
+			"addresses": {
+				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+					"link_name": {
+						Computed:            true,
+						MarkdownDescription: "Name of the link the address is assigned to.",
+						Type:                types.StringType,
+					},
+					"address": {
+						Computed:            true,
+						MarkdownDescription: "Address with prefix length (e.g. `192.168.1.10/24`).",
+						Type:                types.StringType,
+					},
+					"family": {
+						Computed:            true,
+						MarkdownDescription: "Family of the address (`inet4` or `inet6`).",
+						Type:                types.StringType,
+					},
+					"scope": {
+						Computed:            true,
+						MarkdownDescription: "Scope of the address (e.g. `global`, `link`, `host`).",
+						Type:                types.StringType,
+					},
+				}),
+				Computed:            true,
+				MarkdownDescription: "Addresses assigned to the network interfaces.",
+			},
+			"routes": {
+				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+					"out_link_name": {
+						Computed:            true,
+						MarkdownDescription: "Name of the link the route goes through.",
+						Type:                types.StringType,
+					},
+					"family": {
+						Computed:            true,
+						MarkdownDescription: "Family of the route (`inet4` or `inet6`).",
+						Type:                types.StringType,
+					},
+					"destination": {
+						Computed:            true,
+						MarkdownDescription: "Destination prefix of the route, empty for default routes.",
+						Type:                types.StringType,
+					},
+					"gateway": {
+						Computed:            true,
+						MarkdownDescription: "Gateway of the route.",
+						Type:                types.StringType,
+					},
+					"source": {
+						Computed:            true,
+						MarkdownDescription: "Preferred source address of the route.",
+						Type:                types.StringType,
+					},
+					"table": {
+						Computed:            true,
+						MarkdownDescription: "Routing table of the route (e.g. `main`, `local`).",
+						Type:                types.StringType,
+					},
+					"priority": {
+						Computed:            true,
+						MarkdownDescription: "Priority (metric) of the route.",
+						Type:                types.Int64Type,
+					},
+					"scope": {
+						Computed:            true,
+						MarkdownDescription: "Scope of the route (e.g. `global`, `link`, `host`).",
+						Type:                types.StringType,
+					},
+				}),
+				Computed:            true,
+				MarkdownDescription: "Routes of the node.",
+			},
+		}),
+	}, nil
+}
+
+func (d *MachineNetworkDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		clientCredentialsValidator{readerRoles},
+	}
+}
+
+func (d *MachineNetworkDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data *MachineNetworkDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !data.Family.Null && data.Family.Value != nethelpers.FamilyInet4.String() && data.Family.Value != nethelpers.FamilyInet6.String() {
+		resp.Diagnostics.AddError(
+			"Invalid address family",
+			fmt.Sprintf("Address family must be %q or %q, got %q.", nethelpers.FamilyInet4, nethelpers.FamilyInet6, data.Family.Value),
+		)
+		return
+	}
+
+	var links []string
+	resp.Diagnostics.Append(data.Links.ElementsAs(ctx, &links, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	linkSelected := func(name string) bool {
+		if len(links) == 0 {
+			return true
+		}
+		for _, link := range links {
+			if link == name {
+				return true
+			}
+		}
+		return false
+	}
+	familySelected := func(family nethelpers.Family) bool {
+		return data.Family.Null || data.Family.Value == family.String()
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := cosiv1alpha1.NewStateClient(conn)
+	ctx = nodeContext(ctx, data.Node.Value)
+
+	hostnames, err := listResources(ctx, client, network.NamespaceName, network.HostnameStatusType)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading hostname",
+			err.Error(),
+		)
+		return
+	}
+	data.Hostname = types.String{Value: ""}
+	data.Domainname = types.String{Value: ""}
+	for _, res := range hostnames {
+		if res.GetMetadata().GetId() != network.HostnameID {
+			continue
+		}
+		var spec network.HostnameStatusSpec
+		if err := decodeSpec(res, &spec); err != nil {
+			resp.Diagnostics.AddError("Error reading hostname", err.Error())
+			return
+		}
+		data.Hostname = types.String{Value: spec.Hostname}
+		data.Domainname = types.String{Value: spec.Domainname}
+	}
+
+	linkStatuses, err := listResources(ctx, client, network.NamespaceName, network.LinkStatusType)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading network interfaces",
+			err.Error(),
+		)
+		return
+	}
+	data.Interfaces = nil
+	for _, res := range linkStatuses {
+		name := res.GetMetadata().GetId()
+		if !linkSelected(name) {
+			continue
+		}
+		var spec network.LinkStatusSpec
+		if err := decodeSpec(res, &spec); err != nil {
+			resp.Diagnostics.AddError("Error reading network interfaces", err.Error())
+			return
+		}
+		data.Interfaces = append(data.Interfaces, MachineInterfaceModel{
+			Name:             types.String{Value: name},
+			Index:            types.Int64{Value: int64(spec.Index)},
+			Type:             types.String{Value: spec.Type.String()},
+			Kind:             types.String{Value: spec.Kind},
+			HardwareAddr:     types.String{Value: spec.HardwareAddr.String()},
+			Mtu:              types.Int64{Value: int64(spec.MTU)},
+			OperationalState: types.String{Value: spec.OperationalState.String()},
+			LinkState:        types.Bool{Value: spec.LinkState},
+		})
+	}
+
+	addressStatuses, err := listResources(ctx, client, network.NamespaceName, network.AddressStatusType)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading addresses",
+			err.Error(),
+		)
+		return
+	}
+	data.Addresses = nil
+	for _, res := range addressStatuses {
+		var spec network.AddressStatusSpec
+		if err := decodeSpec(res, &spec); err != nil {
+			resp.Diagnostics.AddError("Error reading addresses", err.Error())
+			return
+		}
+		if !linkSelected(spec.LinkName) || !familySelected(spec.Family) {
+			continue
+		}
+		data.Addresses = append(data.Addresses, MachineAddressModel{
+			LinkName: types.String{Value: spec.LinkName},
+			Address:  types.String{Value: addrString(spec.Address)},
+			Family:   types.String{Value: spec.Family.String()},
+			Scope:    types.String{Value: spec.Scope.String()},
+		})
+	}
+
+	routeStatuses, err := listResources(ctx, client, network.NamespaceName, network.RouteStatusType)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading routes",
+			err.Error(),
+		)
+		return
+	}
+	data.Routes = nil
+	for _, res := range routeStatuses {
+		var spec network.RouteStatusSpec
+		if err := decodeSpec(res, &spec); err != nil {
+			resp.Diagnostics.AddError("Error reading routes", err.Error())
+			return
+		}
+		if !linkSelected(spec.OutLinkName) || !familySelected(spec.Family) {
+			continue
+		}
+		data.Routes = append(data.Routes, MachineRouteModel{
+			OutLinkName: types.String{Value: spec.OutLinkName},
+			Family:      types.String{Value: spec.Family.String()},
+			Destination: types.String{Value: addrString(spec.Destination)},
+			Gateway:     types.String{Value: addrString(spec.Gateway)},
+			Source:      types.String{Value: addrString(spec.Source)},
+			Table:       types.String{Value: spec.Table.String()},
+			Priority:    types.Int64{Value: int64(spec.Priority)},
+			Scope:       types.String{Value: spec.Scope.String()},
+		})
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "read a Talos machine network data source")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/talos-systems/talos/pkg/machinery/resources/network"
+)
+
+func TestAccMachineNetworkDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetResource(network.NamespaceName, network.HostnameStatusType, network.HostnameID, `
+hostname: talos-cp-1
+domainname: example.org
+`)
+	talos.SetResource(network.NamespaceName, network.LinkStatusType, "eth0", `
+index: 2
+type: ether
+linkIndex: 0
+flags: UP,BROADCAST,RUNNING,MULTICAST,LOWER_UP
+hardwareAddr: 52:54:00:12:34:56
+broadcastAddr: ff:ff:ff:ff:ff:ff
+mtu: 1500
+queueDisc: fq_codel
+operationalState: up
+kind: ""
+slaveKind: ""
+linkState: true
+`)
+	talos.SetResource(network.NamespaceName, network.LinkStatusType, "lo", `
+index: 1
+type: loopback
+linkIndex: 0
+hardwareAddr: 00:00:00:00:00:00
+mtu: 65536
+operationalState: unknown
+kind: ""
+linkState: false
+`)
+	talos.SetResource(network.NamespaceName, network.AddressStatusType, "eth0/10.5.0.2/24", `
+address: 10.5.0.2/24
+linkIndex: 2
+linkName: eth0
+family: inet4
+scope: global
+`)
+	talos.SetResource(network.NamespaceName, network.AddressStatusType, "eth0/fd00::2/64", `
+address: fd00::2/64
+linkIndex: 2
+linkName: eth0
+family: inet6
+scope: global
+`)
+	talos.SetResource(network.NamespaceName, network.RouteStatusType, "inet4/10.5.0.1//1024", `
+family: inet4
+dst: ""
+src: 10.5.0.2
+gateway: 10.5.0.1
+outLinkIndex: 2
+outLinkName: eth0
+table: main
+priority: 1024
+scope: global
+`)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineNetworkDataSourceConfig(talos, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "hostname", "talos-cp-1"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "domainname", "example.org"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.#", "2"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.0.name", "eth0"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.0.type", "ether"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.0.hardware_addr", "52:54:00:12:34:56"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.0.mtu", "1500"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.0.operational_state", "up"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.0.link_state", "true"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "addresses.#", "2"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "addresses.0.address", "10.5.0.2/24"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "routes.#", "1"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "routes.0.destination", ""),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "routes.0.gateway", "10.5.0.1"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "routes.0.table", "main"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "routes.0.priority", "1024"),
+				),
+			},
+			{
+				Config: testAccMachineNetworkDataSourceConfig(talos, `
+  links  = ["eth0"]
+  family = "inet6"
+`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "interfaces.#", "1"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "addresses.#", "1"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "addresses.0.address", "fd00::2/64"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "addresses.0.family", "inet6"),
+					resource.TestCheckResourceAttr("data.talos_machine_network.test", "routes.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func testAccMachineNetworkDataSourceConfig(talos *fakeTalos, filter string) string {
+	return fmt.Sprintf(`
+data "talos_machine_network" "test" {%s%s}
+`, talos.testClientConfig(), filter)
+}
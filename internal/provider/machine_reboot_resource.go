@@ -0,0 +1,247 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+)
+
+var _ resource.Resource = &MachineRebootResource{}
+
+func NewMachineRebootResource() resource.Resource {
+	return &MachineRebootResource{}
+}
+
+type MachineRebootResource struct{}
+
+type MachineRebootResourceModel struct {
+	ID         types.String `tfsdk:"id"`
+	Endpoint   types.String `tfsdk:"endpoint"`
+	Endpoints  types.List   `tfsdk:"endpoints"`
+	MachineCa  types.String `tfsdk:"machine_ca"`
+	MachineCrt types.String `tfsdk:"machine_crt"`
+	MachineKey types.String `tfsdk:"machine_key"`
+	Node       types.String `tfsdk:"node"`
+	Triggers   types.Map    `tfsdk:"triggers"`
+	Mode       types.String `tfsdk:"mode"`
+	Timeout    types.String `tfsdk:"timeout"`
+	BootID     types.String `tfsdk:"boot_id"`
+}
+
+// bootIDPath is the file holding the random ID generated by the kernel at
+// each boot.
+const bootIDPath = "/proc/sys/kernel/random/boot_id"
+
+func (r *MachineRebootResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_machine_reboot"
+}
+
+func (r *MachineRebootResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Reboot a Talos node when the resource is created or its `triggers` change, " +
+			"and wait for the node to come back with healthy services.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"endpoint": targetEndpointAttribute("node"),
+			"node": {
+				MarkdownDescription: "Node to reboot, reached through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
+			},
+			"triggers": {
+				MarkdownDescription: "Arbitrary values that, when changed, reboot the node.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.MapType{
+					ElemType: types.StringType,
+				},
+			},
+			"mode": {
+				Computed:            true,
+				MarkdownDescription: "Reboot mode, `default` or `powercycle` to skip kexec (default \"default\").",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.String{Value: "default"}),
+				},
+				Type: types.StringType,
+			},
+			"timeout": {
+				Computed:            true,
+				MarkdownDescription: "Maximum time to wait for the node to come back healthy after the reboot (default \"10m\").",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.String{Value: "10m"}),
+				},
+				Type: types.StringType,
+			},
+			"boot_id": {
+				Computed:            true,
+				MarkdownDescription: "Boot ID of the node after the reboot.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+		}),
+	}, nil
+}
+
+func (r *MachineRebootResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		clientCredentialsValidator{adminRoles},
+	}
+}
+
+func (r *MachineRebootResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *MachineRebootResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	mode, ok := machine.RebootRequest_Mode_value[strings.ToUpper(data.Mode.Value)]
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Invalid reboot mode",
+			fmt.Sprintf("Reboot mode must be \"default\" or \"powercycle\", got %q.", data.Mode.Value),
+		)
+		return
+	}
+
+	timeout, err := time.ParseDuration(data.Timeout.Value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error parsing timeout",
+			err.Error(),
+		)
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	bootID, err := rebootNode(nodeContext(ctx, data.Node.Value), client, machine.RebootRequest_Mode(mode), timeout)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error rebooting node",
+			err.Error(),
+		)
+		return
+	}
+	data.BootID = types.String{Value: bootID}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a Talos machine reboot resource")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineRebootResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *MachineRebootResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineRebootResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *MachineRebootResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineRebootResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *MachineRebootResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// rebootNode reboots the node addressed by ctx and waits until it is back
+// with a new boot ID and healthy services, returning the new boot ID.
+func rebootNode(ctx context.Context, client machine.MachineServiceClient, mode machine.RebootRequest_Mode, timeout time.Duration) (string, error) {
+	previous, err := readFile(ctx, client, bootIDPath, noSizeLimit)
+	if err != nil {
+		return "", fmt.Errorf("error reading boot ID: %w", err)
+	}
+	previousID := strings.TrimSpace(string(previous))
+
+	if _, err := client.Reboot(ctx, &machine.RebootRequest{Mode: mode}); err != nil {
+		return "", err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var bootID string
+	if err := waitUntil(waitCtx, defaultPollInterval, func(ctx context.Context) (bool, error) {
+		current, err := readFile(ctx, client, bootIDPath, noSizeLimit)
+		if err != nil {
+			return false, err
+		}
+		bootID = strings.TrimSpace(string(current))
+		return bootID != previousID, nil
+	}); err != nil {
+		return "", fmt.Errorf("node did not come back after the reboot: %w", err)
+	}
+
+	if err := waitUntil(waitCtx, defaultPollInterval, func(ctx context.Context) (bool, error) {
+		return servicesHealthy(ctx, client)
+	}); err != nil {
+		return "", fmt.Errorf("services did not become healthy after the reboot: %w", err)
+	}
+
+	return bootID, nil
+}
@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccMachineRebootResource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineRebootResourceConfig(talos),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_machine_reboot.test", "boot_id", "0fb4d5c4-4f3a-4a3e-9d1b-000000000001"),
+					testCheckCalls(talos, "Reboot", 1),
+				),
+			},
+		},
+	})
+}
+
+func TestAccMachineRebootResource_reindentedCredentials(t *testing.T) {
+	talos := newFakeTalos(t)
+	reindent := func(pem string) string {
+		return "  " + strings.ReplaceAll(strings.TrimSpace(pem), "\n", "\r\n  ") + "\n"
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineRebootResourceConfig(talos),
+			},
+			{
+				Config: fmt.Sprintf(`
+resource "talos_machine_reboot" "test" {
+  endpoint    = %q
+  machine_ca  = %q
+  machine_crt = %q
+  machine_key = %q
+}
+`, talos.Endpoint, reindent(talos.CA), reindent(talos.Crt), reindent(talos.Key)),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccMachineRebootResource_error(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("Reboot", status.Error(codes.FailedPrecondition, "reboot is already in progress"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMachineRebootResourceConfig(talos),
+				ExpectError: regexp.MustCompile("reboot is already in progress"),
+			},
+		},
+	})
+}
+
+func testAccMachineRebootResourceConfig(talos *fakeTalos) string {
+	return fmt.Sprintf(`
+resource "talos_machine_reboot" "test" {%s}
+`, talos.testClientConfig())
+}
@@ -0,0 +1,342 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/common"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var _ resource.Resource = &MachineServiceResource{}
+
+func NewMachineServiceResource() resource.Resource {
+	return &MachineServiceResource{}
+}
+
+type MachineServiceResource struct{}
+
+type MachineServiceResourceModel struct {
+	ID         types.String `tfsdk:"id"`
+	Endpoint   types.String `tfsdk:"endpoint"`
+	Endpoints  types.List   `tfsdk:"endpoints"`
+	MachineCa  types.String `tfsdk:"machine_ca"`
+	MachineCrt types.String `tfsdk:"machine_crt"`
+	MachineKey types.String `tfsdk:"machine_key"`
+	Nodes      types.List   `tfsdk:"nodes"`
+	Service    types.String `tfsdk:"service"`
+	Action     types.String `tfsdk:"action"`
+	Triggers   types.Map    `tfsdk:"triggers"`
+	Timeout    types.String `tfsdk:"timeout"`
+}
+
+// The service actions.
+const (
+	serviceActionStart   = "start"
+	serviceActionStop    = "stop"
+	serviceActionRestart = "restart"
+)
+
+func (r *MachineServiceResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_machine_service"
+}
+
+func (r *MachineServiceResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Start, stop or restart a Talos service on nodes when the resource is created or its `triggers` change.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"endpoint": targetEndpointAttribute("nodes"),
+			"nodes": {
+				MarkdownDescription: "Nodes to run the action on in order, reached through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"service": {
+				MarkdownDescription: "ID of the service (e.g. `kubelet`, `cri`).",
+				Required:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
+			},
+			"action": {
+				Computed:            true,
+				MarkdownDescription: "Action to run: `start`, `stop` or `restart` (default \"restart\").",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.String{Value: serviceActionRestart}),
+					resource.RequiresReplace(),
+				},
+				Type: types.StringType,
+			},
+			"triggers": {
+				MarkdownDescription: "Arbitrary values that, when changed, run the action again.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.RequiresReplace(),
+				},
+				Type: types.MapType{
+					ElemType: types.StringType,
+				},
+			},
+			"timeout": {
+				Computed:            true,
+				MarkdownDescription: "Maximum time to wait for the service on each node after the action (default \"5m\").",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.String{Value: "5m"}),
+				},
+				Type: types.StringType,
+			},
+		}),
+	}, nil
+}
+
+func (r *MachineServiceResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		clientCredentialsValidator{adminRoles},
+	}
+}
+
+func (r *MachineServiceResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *MachineServiceResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	action := data.Action.Value
+	if action != serviceActionStart && action != serviceActionStop && action != serviceActionRestart {
+		resp.Diagnostics.AddError(
+			"Invalid service action",
+			fmt.Sprintf("Service action must be %q, %q or %q, got %q.", serviceActionStart, serviceActionStop, serviceActionRestart, action),
+		)
+		return
+	}
+
+	timeout, err := time.ParseDuration(data.Timeout.Value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error parsing timeout",
+			err.Error(),
+		)
+		return
+	}
+
+	var nodes []string
+	resp.Diagnostics.Append(data.Nodes.ElementsAs(ctx, &nodes, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if len(nodes) == 0 {
+		nodes = []string{""}
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	for _, node := range nodes {
+		name := node
+		if name == "" {
+			name = data.Endpoint.Value
+		}
+
+		if err := runServiceAction(nodeContext(ctx, node), client, data.Service.Value, action, timeout); err != nil {
+			resp.Diagnostics.AddError(
+				fmt.Sprintf("Error running %s of service %s on node %s", action, data.Service.Value, name),
+				err.Error(),
+			)
+			return
+		}
+
+		tflog.Info(ctx, "ran Talos service action", map[string]interface{}{"node": name, "service": data.Service.Value, "action": action})
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a Talos machine service resource")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineServiceResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *MachineServiceResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineServiceResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data *MachineServiceResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineServiceResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *MachineServiceResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// runServiceAction runs action on the service of the node addressed by ctx,
+// then waits until the service is running and healthy, or finished if it was
+// stopped.
+func runServiceAction(ctx context.Context, client machine.MachineServiceClient, id, action string, timeout time.Duration) error {
+	before, err := serviceInfo(ctx, client, id)
+	if err != nil {
+		return err
+	}
+	lastEvent := lastServiceEvent(before)
+
+	var metadata []*common.Metadata
+	switch action {
+	case serviceActionStart:
+		if before.State == "Running" {
+			break
+		}
+		resp, err := client.ServiceStart(ctx, &machine.ServiceStartRequest{Id: id})
+		if err != nil {
+			return err
+		}
+		for _, msg := range resp.Messages {
+			metadata = append(metadata, msg.Metadata)
+		}
+	case serviceActionStop:
+		resp, err := client.ServiceStop(ctx, &machine.ServiceStopRequest{Id: id})
+		if err != nil {
+			return err
+		}
+		for _, msg := range resp.Messages {
+			metadata = append(metadata, msg.Metadata)
+		}
+	case serviceActionRestart:
+		resp, err := client.ServiceRestart(ctx, &machine.ServiceRestartRequest{Id: id})
+		if err != nil {
+			return err
+		}
+		for _, msg := range resp.Messages {
+			metadata = append(metadata, msg.Metadata)
+		}
+	}
+	for _, md := range metadata {
+		if md != nil && md.Error != "" {
+			return errors.New(md.Error)
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var state string
+	err = waitUntil(waitCtx, defaultPollInterval, func(ctx context.Context) (bool, error) {
+		info, err := serviceInfo(ctx, client, id)
+		if err != nil {
+			return false, err
+		}
+		state = info.State
+
+		switch action {
+		case serviceActionStop:
+			return info.State == "Finished", nil
+		case serviceActionRestart:
+			// The service must have gone through new events, otherwise
+			// the previous instance is still being reported.
+			if !lastServiceEvent(info).After(lastEvent) {
+				return false, nil
+			}
+		}
+
+		// Services without health check have no health, the others are
+		// not ready until their first check passed.
+		return info.State == "Running" && (info.Health == nil || !info.Health.Unknown && info.Health.Healthy), nil
+	})
+	if err != nil {
+		return fmt.Errorf("last service state %q: %w", state, err)
+	}
+
+	return nil
+}
+
+// serviceInfo returns the service with the given ID of the node addressed by
+// ctx.
+func serviceInfo(ctx context.Context, client machine.MachineServiceClient, id string) (*machine.ServiceInfo, error) {
+	resp, err := client.ServiceList(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, msg := range resp.Messages {
+		for _, svc := range msg.Services {
+			if svc.Id == id {
+				return svc, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("service %s not found", id)
+}
+
+// lastServiceEvent returns the time of the last event of the service.
+func lastServiceEvent(info *machine.ServiceInfo) time.Time {
+	var last time.Time
+	for _, event := range info.GetEvents().GetEvents() {
+		if ts := event.GetTs().AsTime(); ts.After(last) {
+			last = ts
+		}
+	}
+	return last
+}
@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccMachineServiceResource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineServiceResourceConfig(talos, "kubelet", "restart"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_machine_service.test", "service", "kubelet"),
+					testCheckCalls(talos, "ServiceRestart", 1),
+					// Before the restart, then until the first health check
+					// of the new instance passed
+					testCheckCalls(talos, "ServiceList", 3),
+				),
+			},
+		},
+	})
+}
+
+func TestAccMachineServiceResource_stopStart(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineServiceResourceConfig(talos, "etcd", "stop"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testCheckCalls(talos, "ServiceStop", 1),
+					testCheckCalls(talos, "ServiceList", 2),
+				),
+			},
+			{
+				Config: testAccMachineServiceResourceConfig(talos, "etcd", "start"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testCheckCalls(talos, "ServiceStart", 1),
+					testCheckCalls(talos, "ServiceList", 5),
+				),
+			},
+		},
+	})
+}
+
+func TestAccMachineServiceResource_notFound(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMachineServiceResourceConfig(talos, "apid", "restart"),
+				ExpectError: regexp.MustCompile("apid"),
+			},
+		},
+	})
+}
+
+func testAccMachineServiceResourceConfig(talos *fakeTalos, service, action string) string {
+	return fmt.Sprintf(`
+resource "talos_machine_service" "test" {%s
+  service = %q
+  action  = %q
+}
+`, talos.testClientConfig(), service, action)
+}
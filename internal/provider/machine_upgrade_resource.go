@@ -0,0 +1,421 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/cluster"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"github.com/tensor5/terraform-provider-talos/internal/provider/attribute_plan_modifier"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var _ resource.Resource = &MachineUpgradeResource{}
+
+func NewMachineUpgradeResource() resource.Resource {
+	return &MachineUpgradeResource{}
+}
+
+type MachineUpgradeResource struct{}
+
+type MachineUpgradeResourceModel struct {
+	ID                types.String `tfsdk:"id"`
+	Endpoint          types.String `tfsdk:"endpoint"`
+	Endpoints         types.List   `tfsdk:"endpoints"`
+	MachineCa         types.String `tfsdk:"machine_ca"`
+	MachineCrt        types.String `tfsdk:"machine_crt"`
+	MachineKey        types.String `tfsdk:"machine_key"`
+	Nodes             types.List   `tfsdk:"nodes"`
+	ControlPlaneNodes types.List   `tfsdk:"control_plane_nodes"`
+	WorkerNodes       types.List   `tfsdk:"worker_nodes"`
+	Image             types.String `tfsdk:"image"`
+	Preserve          types.Bool   `tfsdk:"preserve"`
+	Stage             types.Bool   `tfsdk:"stage"`
+	Force             types.Bool   `tfsdk:"force"`
+	Timeout           types.String `tfsdk:"timeout"`
+	Versions          types.Map    `tfsdk:"versions"`
+}
+
+func (r *MachineUpgradeResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_machine_upgrade"
+}
+
+func (r *MachineUpgradeResource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Upgrade Talos nodes to a new installer image, one node at a time.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					resource.UseStateForUnknown(),
+				},
+				Type: types.StringType,
+			},
+			"endpoint": targetEndpointAttribute("nodes"),
+			"nodes": {
+				MarkdownDescription: "Nodes to upgrade in order, reached through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"control_plane_nodes": {
+				MarkdownDescription: "Control plane nodes of the cluster, reached through `endpoint`. When set, each upgraded node is followed by the checks of `talos_cluster_health`, which must pass before the next node is upgraded.",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"worker_nodes": {
+				MarkdownDescription: "Worker nodes of the cluster, checked along with `control_plane_nodes`.",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"image": {
+				MarkdownDescription: "The installer image to upgrade to (e.g. `ghcr.io/siderolabs/installer:v1.2.4`).",
+				Required:            true,
+				Type:                types.StringType,
+			},
+			"preserve": {
+				Computed:            true,
+				MarkdownDescription: "Preserve data (the EPHEMERAL partition) during the upgrade.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.Bool{Value: false}),
+				},
+				Type: types.BoolType,
+			},
+			"stage": {
+				Computed:            true,
+				MarkdownDescription: "Stage the upgrade to perform it after a reboot.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.Bool{Value: false}),
+				},
+				Type: types.BoolType,
+			},
+			"force": {
+				Computed:            true,
+				MarkdownDescription: "Force the upgrade, skipping the etcd health and member checks.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.Bool{Value: false}),
+				},
+				Type: types.BoolType,
+			},
+			"timeout": {
+				Computed:            true,
+				MarkdownDescription: "Maximum time to wait for each node to come back healthy after the upgrade, and then for the cluster to be healthy (default \"10m\"). Changing it does not upgrade the nodes again.",
+				Optional:            true,
+				PlanModifiers: []tfsdk.AttributePlanModifier{
+					attribute_plan_modifier.DefaultValue(types.String{Value: "10m"}),
+				},
+				Type: types.StringType,
+			},
+			"versions": {
+				Computed:            true,
+				MarkdownDescription: "Talos version reported by each node after the upgrade.",
+				Type: types.MapType{
+					ElemType: types.StringType,
+				},
+			},
+		}),
+	}, nil
+}
+
+func (r *MachineUpgradeResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		clientCredentialsValidator{adminRoles},
+	}
+}
+
+func (r *MachineUpgradeResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data *MachineUpgradeResourceModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(r.upgrade(ctx, data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "created a Talos machine upgrade resource")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineUpgradeResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data *MachineUpgradeResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineUpgradeResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state *MachineUpgradeResourceModel
+
+	// Read Terraform plan and prior state data into the models
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Other changes, such as the timeout or the credentials, only apply to
+	// the next upgrade.
+	if upgradeChanged(state, data) {
+		resp.Diagnostics.Append(r.upgrade(ctx, data)...)
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	} else {
+		data.Versions = state.Versions
+	}
+
+	// Save updated data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *MachineUpgradeResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data *MachineUpgradeResourceModel
+
+	// Read Terraform prior state data into the model
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+}
+
+// upgradeChanged tells whether the nodes must be upgraded again to apply the
+// plan.
+func upgradeChanged(state, plan *MachineUpgradeResourceModel) bool {
+	return !state.Nodes.Equal(plan.Nodes) ||
+		!state.Image.Equal(plan.Image) ||
+		!state.Preserve.Equal(plan.Preserve) ||
+		!state.Stage.Equal(plan.Stage) ||
+		!state.Force.Equal(plan.Force)
+}
+
+// upgrade upgrades the nodes one at a time, waiting for each node to come back
+// with the new version and healthy services, and for the cluster to be
+// healthy if its nodes are known, before moving to the next one.
+func (r *MachineUpgradeResource) upgrade(ctx context.Context, data *MachineUpgradeResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	timeout, err := time.ParseDuration(data.Timeout.Value)
+	if err != nil {
+		diags.AddError(
+			"Error parsing timeout",
+			err.Error(),
+		)
+		return diags
+	}
+
+	var nodes []string
+	diags.Append(data.Nodes.ElementsAs(ctx, &nodes, false)...)
+	if diags.HasError() {
+		return diags
+	}
+	if len(nodes) == 0 {
+		nodes = []string{""}
+	}
+
+	var clusterInfo *cluster.ClusterInfo
+	if !data.ControlPlaneNodes.Null {
+		clusterInfo = &cluster.ClusterInfo{}
+		diags.Append(data.ControlPlaneNodes.ElementsAs(ctx, &clusterInfo.ControlPlaneNodes, false)...)
+		diags.Append(data.WorkerNodes.ElementsAs(ctx, &clusterInfo.WorkerNodes, false)...)
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	conn, connDiags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	diags.Append(connDiags...)
+	if diags.HasError() {
+		return diags
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	expectedTag := imageTag(data.Image.Value)
+	versions := map[string]attr.Value{}
+
+	for _, node := range nodes {
+		name := node
+		if name == "" {
+			name = data.Endpoint.Value
+		}
+
+		version, upgraded, err := upgradeNode(ctx, client, node, &machine.UpgradeRequest{
+			Image:    data.Image.Value,
+			Preserve: data.Preserve.Value,
+			Stage:    data.Stage.Value,
+			Force:    data.Force.Value,
+		}, expectedTag, timeout)
+		if err != nil {
+			diags.AddError(
+				fmt.Sprintf("Error upgrading node %s", name),
+				err.Error(),
+			)
+			return diags
+		}
+
+		tflog.Info(ctx, "upgraded Talos node", map[string]interface{}{"node": name, "version": version})
+		versions[name] = types.String{Value: version}
+
+		if upgraded && clusterInfo != nil {
+			if _, err := healthCheck(ctx, cluster.NewClusterServiceClient(conn), clusterInfo, timeout); err != nil {
+				diags.AddError(
+					fmt.Sprintf("Cluster is not healthy after upgrading node %s", name),
+					err.Error(),
+				)
+				return diags
+			}
+		}
+	}
+
+	data.Versions = types.Map{ElemType: types.StringType, Elems: versions}
+
+	return diags
+}
+
+// upgradeNode upgrades a single node, unless it already runs expectedTag, and
+// returns the version it reports once it is back and healthy, and whether it
+// was upgraded.
+func upgradeNode(ctx context.Context, client machine.MachineServiceClient, node string, req *machine.UpgradeRequest, expectedTag string, timeout time.Duration) (string, bool, error) {
+	ctx = nodeContext(ctx, node)
+
+	current, err := nodeVersion(ctx, client)
+	if err != nil {
+		return "", false, err
+	}
+	if expectedTag != "" && current.Tag == expectedTag {
+		return current.Tag, false, nil
+	}
+
+	if _, err := client.Upgrade(ctx, req); err != nil {
+		return "", false, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Without a tag to compare to, the node is considered upgraded once it
+	// has been unreachable and answers again.
+	var version *machine.VersionInfo
+	wentDown := false
+	err = waitUntil(waitCtx, defaultPollInterval, func(ctx context.Context) (bool, error) {
+		v, err := nodeVersion(ctx, client)
+		if err != nil {
+			wentDown = true
+			return false, err
+		}
+		version = v
+		if expectedTag != "" {
+			return v.Tag == expectedTag, nil
+		}
+		return wentDown, nil
+	})
+	if err != nil {
+		if version != nil && expectedTag != "" {
+			return "", true, fmt.Errorf("node reports version %s instead of %s: %w", version.Tag, expectedTag, err)
+		}
+		return "", true, fmt.Errorf("node did not come back after the upgrade: %w", err)
+	}
+
+	if err := waitUntil(waitCtx, defaultPollInterval, func(ctx context.Context) (bool, error) {
+		return servicesHealthy(ctx, client)
+	}); err != nil {
+		return "", true, fmt.Errorf("services did not become healthy after the upgrade: %w", err)
+	}
+
+	return version.Tag, true, nil
+}
+
+// nodeVersion returns the version information reported by the node
+// addressed by ctx.
+func nodeVersion(ctx context.Context, client machine.MachineServiceClient) (*machine.VersionInfo, error) {
+	resp, err := client.Version(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Messages) == 0 || resp.Messages[0].Version == nil {
+		return nil, errors.New("empty version response")
+	}
+	return resp.Messages[0].Version, nil
+}
+
+// servicesHealthy reports whether all the services of the node addressed by
+// ctx are running and healthy, or finished.
+func servicesHealthy(ctx context.Context, client machine.MachineServiceClient) (bool, error) {
+	resp, err := client.ServiceList(ctx, &emptypb.Empty{})
+	if err != nil {
+		return false, err
+	}
+
+	for _, msg := range resp.Messages {
+		for _, svc := range msg.Services {
+			switch svc.State {
+			case "Finished", "Skipped":
+				continue
+			case "Running":
+				if svc.Health != nil && !svc.Health.Unknown && !svc.Health.Healthy {
+					return false, nil
+				}
+			default:
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// imageTag returns the tag of a container image reference, or an empty
+// string if the reference has no tag.
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+
+	slash := strings.LastIndex(image, "/")
+	colon := strings.LastIndex(image, ":")
+	if colon > slash {
+		return image[colon+1:]
+	}
+
+	return ""
+}
@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccMachineUpgradeResource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineUpgradeResourceConfig(talos, "ghcr.io/siderolabs/installer:v1.2.4"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_machine_upgrade.test", "versions.%", "1"),
+					resource.TestCheckResourceAttr("talos_machine_upgrade.test", "versions.10.5.0.2", "v1.2.4"),
+					testCheckCalls(talos, "Upgrade", 1),
+				),
+			},
+		},
+	})
+}
+
+func TestAccMachineUpgradeResource_upToDate(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineUpgradeResourceConfig(talos, "ghcr.io/siderolabs/installer:v1.2.3"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_machine_upgrade.test", "versions.10.5.0.2", "v1.2.3"),
+					testCheckCalls(talos, "Upgrade", 0),
+				),
+			},
+		},
+	})
+}
+
+func TestAccMachineUpgradeResource_clusterHealth(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineUpgradeResourceClusterConfig(talos, `timeout = "10m"`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_machine_upgrade.test", "versions.%", "2"),
+					// The fake nodes share their version, the second one is
+					// already upgraded, so the cluster is checked once.
+					testCheckCalls(talos, "Upgrade", 1),
+					testCheckCalls(talos, "HealthCheck", 1),
+				),
+			},
+		},
+	})
+}
+
+func TestAccMachineUpgradeResource_unhealthyCluster(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Fail("HealthCheck", status.Error(codes.DeadlineExceeded, "timeout waiting for etcd to be healthy"))
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccMachineUpgradeResourceClusterConfig(talos, `timeout = "10m"`),
+				ExpectError: regexp.MustCompile("Cluster is not healthy after upgrading node 10.5.0.2"),
+			},
+		},
+	})
+}
+
+func TestAccMachineUpgradeResource_updateTimeout(t *testing.T) {
+	talos := newFakeTalos(t)
+	var versionCalls int
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccMachineUpgradeResourceClusterConfig(talos, `timeout = "10m"`),
+			},
+			{
+				PreConfig: func() { versionCalls = talos.Calls("Version") },
+				Config:    testAccMachineUpgradeResourceClusterConfig(talos, `timeout = "20m"`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("talos_machine_upgrade.test", "timeout", "20m"),
+					resource.TestCheckResourceAttr("talos_machine_upgrade.test", "versions.10.5.0.3", "v1.2.4"),
+					func(*terraform.State) error {
+						if calls := talos.Calls("Version"); calls != versionCalls {
+							return fmt.Errorf("nodes were upgraded again, Version called %d more times", calls-versionCalls)
+						}
+						return nil
+					},
+					testCheckCalls(talos, "HealthCheck", 1),
+				),
+			},
+		},
+	})
+}
+
+func testAccMachineUpgradeResourceConfig(talos *fakeTalos, image string) string {
+	return fmt.Sprintf(`
+resource "talos_machine_upgrade" "test" {%s
+  nodes = ["10.5.0.2"]
+  image = %q
+}
+`, talos.testClientConfig(), image)
+}
+
+func testAccMachineUpgradeResourceClusterConfig(talos *fakeTalos, timeout string) string {
+	return fmt.Sprintf(`
+resource "talos_machine_upgrade" "test" {%s
+  nodes               = ["10.5.0.2", "10.5.0.3"]
+  control_plane_nodes = ["10.5.0.2"]
+  worker_nodes        = ["10.5.0.3"]
+  image               = "ghcr.io/siderolabs/installer:v1.2.4"
+  %s
+}
+`, talos.testClientConfig(), timeout)
+}
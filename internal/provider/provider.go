@@ -29,6 +29,8 @@ func (p *TalosProvider) Metadata(ctx context.Context, req provider.MetadataReque
 
 func (p *TalosProvider) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
 	return tfsdk.Schema{
+		MarkdownDescription: "Connections to the Talos API go through the proxy set in `HTTPS_PROXY`, except for the addresses in `NO_PROXY`, " +
+			"and wait up to `TALOS_DIAL_TIMEOUT` (default \"1m\") for the nodes to be reachable.",
 		Attributes: map[string]tfsdk.Attribute{},
 	}, nil
 }
@@ -46,13 +48,26 @@ func (p *TalosProvider) Configure(ctx context.Context, req provider.ConfigureReq
 func (p *TalosProvider) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewBootstrapResource,
+		NewEtcdMemberResource,
+		NewEtcdSnapshotResource,
 		NewGenConfigResource,
+		NewKubernetesUpgradeResource,
+		NewMachineRebootResource,
+		NewMachineServiceResource,
+		NewMachineUpgradeResource,
 	}
 }
 
 func (p *TalosProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
 	return []func() datasource.DataSource{
+		NewClusterHealthDataSource,
+		NewEtcdMembersDataSource,
 		NewKubeconfigDataSource,
+		NewMachineDisksDataSource,
+		NewMachineFileDataSource,
+		NewMachineNetworkDataSource,
+		NewResourcesDataSource,
+		NewVersionDataSource,
 	}
 }
 
@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+	"google.golang.org/grpc"
+)
+
+// proxyDefaultPorts are the default ports of the supported proxy schemes.
+var proxyDefaultPorts = map[string]string{
+	"http":    "80",
+	"https":   "443",
+	"socks5":  "1080",
+	"socks5h": "1080",
+}
+
+// withProxy returns a dial option connecting to the Talos API through the
+// proxy at proxyURL, except for the addresses matched by NO_PROXY. If
+// proxyURL is empty, the proxy is taken from HTTPS_PROXY, as done by default
+// for every connection.
+func withProxy(proxyURL string) (grpc.DialOption, error) {
+	config := httpproxy.FromEnvironment()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := proxyDefaultPorts[u.Scheme]; !ok {
+			return nil, fmt.Errorf("unsupported proxy scheme %q, must be http, https, socks5 or socks5h", u.Scheme)
+		}
+		config.HTTPSProxy = proxyURL
+	}
+
+	proxyFunc := config.ProxyFunc()
+	return grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		proxyURL, err := proxyFunc(&url.URL{Scheme: "https", Host: addr})
+		if err != nil {
+			return nil, err
+		}
+		return dialProxy(ctx, proxyURL, addr)
+	}), nil
+}
+
+// dialProxy connects to addr through the proxy at proxyURL, or directly if
+// proxyURL is nil.
+func dialProxy(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	var direct net.Dialer
+	if proxyURL == nil {
+		return direct.DialContext(ctx, "tcp", addr)
+	}
+
+	port, ok := proxyDefaultPorts[proxyURL.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		// Like curl, socks5 resolves the host of addr locally while socks5h
+		// lets the proxy resolve it.
+		if proxyURL.Scheme == "socks5" {
+			var err error
+			if addr, err = resolveAddr(ctx, addr); err != nil {
+				return nil, err
+			}
+		}
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyAddr, auth, &direct)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	default:
+		return dialHTTPConnect(ctx, proxyURL, proxyAddr, addr)
+	}
+}
+
+// resolveAddr replaces the host of addr with its first IP address.
+func resolveAddr(ctx context.Context, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// dialHTTPConnect connects to addr through the HTTP proxy at proxyAddr with
+// the CONNECT method.
+func dialHTTPConnect(ctx context.Context, proxyURL *url.URL, proxyAddr, addr string) (_ net.Conn, err error) {
+	var direct net.Dialer
+	conn, err := direct.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", proxyURL.Redacted(), addr, resp.Status)
+	}
+
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were already read into a
+// buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
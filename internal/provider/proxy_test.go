@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDialProxySOCKS5(t *testing.T) {
+	target := startGreetingServer(t)
+	_, port, _ := net.SplitHostPort(target)
+
+	for name, tt := range map[string]struct {
+		proxyUser *url.Userinfo
+		user      *url.Userinfo
+		scheme    string
+		host      string
+		fail      bool
+	}{
+		"socks5 resolves locally": {
+			scheme: "socks5",
+			host:   "127.0.0.1",
+		},
+		"socks5h resolves on the proxy": {
+			scheme: "socks5h",
+			host:   "localhost",
+		},
+		"auth": {
+			proxyUser: url.UserPassword("user", "password"),
+			user:      url.UserPassword("user", "password"),
+			scheme:    "socks5h",
+			host:      "localhost",
+		},
+		"wrong password": {
+			proxyUser: url.UserPassword("user", "password"),
+			user:      url.UserPassword("user", "wrong"),
+			scheme:    "socks5h",
+			fail:      true,
+		},
+	} {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			proxy := startSOCKS5Server(t, tt.proxyUser, target)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			conn, err := dialProxy(ctx, &url.URL{Scheme: tt.scheme, User: tt.user, Host: proxy.addr}, net.JoinHostPort("localhost", port))
+			if tt.fail {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGreeting(t, conn)
+
+			if host := proxy.requestedHost(); host != tt.host && !(tt.host == "127.0.0.1" && host == "::1") {
+				t.Errorf("proxy was asked for %q, expected %q", host, tt.host)
+			}
+		})
+	}
+}
+
+func TestDialProxyHTTPConnect(t *testing.T) {
+	target := startGreetingServer(t)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("user:password")) {
+			http.Error(w, "", http.StatusProxyAuthRequired)
+			return
+		}
+		if r.Host != "talos.example.com:50000" {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		// The greeting of the target may be read along with the response,
+		// and then served from the buffer of the client.
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipe(conn, upstream)
+	}))
+	t.Cleanup(proxy.Close)
+	proxyHost := strings.TrimPrefix(proxy.URL, "http://")
+
+	for name, tt := range map[string]struct {
+		user  *url.Userinfo
+		addr  string
+		error string
+	}{
+		"auth": {
+			user: url.UserPassword("user", "password"),
+			addr: "talos.example.com:50000",
+		},
+		"missing auth": {
+			addr:  "talos.example.com:50000",
+			error: "407 Proxy Authentication Required",
+		},
+		"refused": {
+			user:  url.UserPassword("user", "password"),
+			addr:  "other.example.com:50000",
+			error: "refused to connect to other.example.com:50000: 403 Forbidden",
+		},
+	} {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			proxyURL := &url.URL{Scheme: "http", User: tt.user, Host: proxyHost}
+			conn, err := dialProxy(ctx, proxyURL, tt.addr)
+			if tt.error != "" {
+				if err == nil {
+					conn.Close()
+					t.Fatal("expected an error")
+				}
+				if !strings.Contains(err.Error(), tt.error) {
+					t.Errorf("unexpected error %q, expected %q", err, tt.error)
+				}
+				if strings.Contains(err.Error(), "password") {
+					t.Errorf("error %q discloses the password of the proxy", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGreeting(t, conn)
+		})
+	}
+}
+
+const proxyTestGreeting = "talos"
+
+// startGreetingServer starts a TCP server writing proxyTestGreeting to its
+// clients, and returns its address.
+func startGreetingServer(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(proxyTestGreeting))
+			conn.Close()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// assertGreeting checks that proxyTestGreeting is read from conn, and closes
+// it.
+func assertGreeting(t *testing.T, conn net.Conn) {
+	t.Helper()
+	defer conn.Close()
+
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != proxyTestGreeting {
+		t.Errorf("read %q through the proxy, expected %q", got, proxyTestGreeting)
+	}
+}
+
+// socks5Server is a minimal SOCKS5 proxy connecting every client to the same
+// target, whatever the requested address.
+type socks5Server struct {
+	addr   string
+	user   *url.Userinfo
+	target string
+
+	mu   sync.Mutex
+	host string
+}
+
+// startSOCKS5Server starts a SOCKS5 proxy to target, requiring the given
+// credentials if user is not nil.
+func startSOCKS5Server(t *testing.T, user *url.Userinfo, target string) *socks5Server {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &socks5Server{addr: l.Addr().String(), user: user, target: target}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// requestedHost returns the host of the last CONNECT request, as an IP
+// address or a name to be resolved by the proxy.
+func (s *socks5Server) requestedHost() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.host
+}
+
+func (s *socks5Server) serve(conn net.Conn) {
+	if err := s.handshake(conn); err != nil {
+		conn.Close()
+		return
+	}
+
+	upstream, err := net.Dial("tcp", s.target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		conn.Close()
+		upstream.Close()
+		return
+	}
+	pipe(conn, upstream)
+}
+
+// handshake negotiates the authentication method and reads the CONNECT
+// request of a client, as described in RFC 1928 and RFC 1929.
+func (s *socks5Server) handshake(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	if s.user == nil {
+		if _, err := conn.Write([]byte{5, 0}); err != nil {
+			return err
+		}
+	} else {
+		if _, err := conn.Write([]byte{5, 2}); err != nil {
+			return err
+		}
+		username, err := readSOCKS5String(conn, 1)
+		if err != nil {
+			return err
+		}
+		password, err := readSOCKS5String(conn, 0)
+		if err != nil {
+			return err
+		}
+		if expected, _ := s.user.Password(); username != s.user.Username() || password != expected {
+			conn.Write([]byte{1, 1})
+			return io.EOF
+		}
+		if _, err := conn.Write([]byte{1, 0}); err != nil {
+			return err
+		}
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return err
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if request[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return err
+		}
+		host = ip.String()
+	case 3:
+		name, err := readSOCKS5String(conn, 0)
+		if err != nil {
+			return err
+		}
+		host = name
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 2)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.host = host
+	s.mu.Unlock()
+	return nil
+}
+
+// readSOCKS5String reads a string prefixed with its length, after skip bytes.
+func readSOCKS5String(r io.Reader, skip int) (string, error) {
+	buf := make([]byte, skip+1)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	s := make([]byte, buf[skip])
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+// pipe copies data between two connections until one of them is closed.
+func pipe(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	cosiv1alpha1 "github.com/cosi-project/runtime/api/v1alpha1"
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+var _ datasource.DataSource = &ResourcesDataSource{}
+
+func NewResourcesDataSource() datasource.DataSource {
+	return &ResourcesDataSource{}
+}
+
+type ResourcesDataSource struct{}
+
+type ResourcesDataSourceModel struct {
+	Endpoint   types.String    `tfsdk:"endpoint"`
+	Endpoints  types.List      `tfsdk:"endpoints"`
+	MachineCa  types.String    `tfsdk:"machine_ca"`
+	MachineCrt types.String    `tfsdk:"machine_crt"`
+	MachineKey types.String    `tfsdk:"machine_key"`
+	Node       types.String    `tfsdk:"node"`
+	Namespace  types.String    `tfsdk:"namespace"`
+	Type       types.String    `tfsdk:"type"`
+	ID         types.String    `tfsdk:"id"`
+	Resources  []ResourceModel `tfsdk:"resources"`
+}
+
+type ResourceModel struct {
+	Namespace types.String `tfsdk:"namespace"`
+	Type      types.String `tfsdk:"type"`
+	ID        types.String `tfsdk:"id"`
+	Version   types.String `tfsdk:"version"`
+	Phase     types.String `tfsdk:"phase"`
+	Labels    types.Map    `tfsdk:"labels"`
+	Spec      types.String `tfsdk:"spec"`
+	SpecJSON  types.String `tfsdk:"spec_json"`
+}
+
+func (d *ResourcesDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_resources"
+}
+
+func (d *ResourcesDataSource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Read Talos resources from a node, as `talosctl get` does. " +
+			"Use `jsondecode` or `yamldecode` on the `spec_json` or `spec` attributes to access the fields of the resources.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"node": {
+				MarkdownDescription: "Node to query through `endpoint` (defaults to the node at `endpoint`).",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"namespace": {
+				MarkdownDescription: "Namespace of the resources (defaults to the namespace of the resource type).",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"type": {
+				MarkdownDescription: "Type of the resources, or one of its aliases (e.g. `members`, `nodename`).",
+				Required:            true,
+				Type:                types.StringType,
+			},
+			"id": {
+				MarkdownDescription: "ID of the resource to read (defaults to all the resources of `type`).",
+				Optional:            true,
+				Type:                types.StringType,
+			},
+			"resources": {
+				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+					"namespace": {
+						Computed:            true,
+						MarkdownDescription: "Namespace of the resource.",
+						Type:                types.StringType,
+					},
+					"type": {
+						Computed:            true,
+						MarkdownDescription: "Type of the resource.",
+						Type:                types.StringType,
+					},
+					"id": {
+						Computed:            true,
+						MarkdownDescription: "ID of the resource.",
+						Type:                types.StringType,
+					},
+					"version": {
+						Computed:            true,
+						MarkdownDescription: "Version of the resource.",
+						Type:                types.StringType,
+					},
+					"phase": {
+						Computed:            true,
+						MarkdownDescription: "Phase of the resource (`running` or `tearingDown`).",
+						Type:                types.StringType,
+					},
+					"labels": {
+						Computed:            true,
+						MarkdownDescription: "Labels of the resource.",
+						Type: types.MapType{
+							ElemType: types.StringType,
+						},
+					},
+					"spec": {
+						Computed:            true,
+						MarkdownDescription: "Spec of the resource, encoded in YAML.",
+						Type:                types.StringType,
+					},
+					"spec_json": {
+						Computed:            true,
+						MarkdownDescription: "Spec of the resource, encoded in JSON.",
+						Type:                types.StringType,
+					},
+				}),
+				Computed:            true,
+				MarkdownDescription: "Resources matching the query.",
+			},
+		}),
+	}, nil
+}
+
+func (d *ResourcesDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		clientCredentialsValidator{readerRoles},
+	}
+}
+
+func (d *ResourcesDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data *ResourcesDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	conn, diags := dialTalos(ctx, data.Endpoint, data.Endpoints, data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	defer conn.Close()
+	client := cosiv1alpha1.NewStateClient(conn)
+	ctx = nodeContext(ctx, data.Node.Value)
+
+	definition, err := resolveResourceType(ctx, client, data.Type.Value)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error resolving resource type",
+			err.Error(),
+		)
+		return
+	}
+
+	namespace := definition.DefaultNamespace
+	if !data.Namespace.Null {
+		namespace = data.Namespace.Value
+	}
+
+	var resources []*cosiv1alpha1.Resource
+	if data.ID.Null {
+		resources, err = listResources(ctx, client, namespace, definition.Type)
+	} else {
+		var getResp *cosiv1alpha1.GetResponse
+		getResp, err = client.Get(ctx, &cosiv1alpha1.GetRequest{
+			Namespace: namespace,
+			Type:      definition.Type,
+			Id:        data.ID.Value,
+		})
+		resources = []*cosiv1alpha1.Resource{getResp.GetResource()}
+	}
+	if err != nil {
+		resp.Diagnostics.AddError(
+			fmt.Sprintf("Error reading %s resources", definition.Type),
+			err.Error(),
+		)
+		return
+	}
+
+	data.Resources = nil
+	for _, res := range resources {
+		model, err := resourceModel(res)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				fmt.Sprintf("Error reading %s resources", definition.Type),
+				err.Error(),
+			)
+			return
+		}
+		data.Resources = append(data.Resources, model)
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "read a Talos resources data source")
+
+	// Save data into Terraform state
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// resourceModel converts a COSI resource to its Terraform model.
+func resourceModel(res *cosiv1alpha1.Resource) (ResourceModel, error) {
+	metadata := res.GetMetadata()
+	spec := res.GetSpec().GetYamlSpec()
+
+	specJSON, err := yaml.YAMLToJSON([]byte(spec))
+	if err != nil {
+		return ResourceModel{}, fmt.Errorf("error decoding %s %s: %w", metadata.GetType(), metadata.GetId(), err)
+	}
+
+	labels := map[string]attr.Value{}
+	for key, value := range metadata.GetLabels() {
+		labels[key] = types.String{Value: value}
+	}
+
+	return ResourceModel{
+		Namespace: types.String{Value: metadata.GetNamespace()},
+		Type:      types.String{Value: metadata.GetType()},
+		ID:        types.String{Value: metadata.GetId()},
+		Version:   types.String{Value: metadata.GetVersion()},
+		Phase:     types.String{Value: metadata.GetPhase()},
+		Labels:    types.Map{ElemType: types.StringType, Elems: labels},
+		Spec:      types.String{Value: spec},
+		SpecJSON:  types.String{Value: string(specJSON)},
+	}, nil
+}
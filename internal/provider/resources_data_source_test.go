@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+func TestAccResourcesDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.SetResource(metaNamespace, resourceDefinitionType, "hostnamestatuses.net.talos.dev", `
+type: HostnameStatuses.net.talos.dev
+displayType: HostnameStatus
+aliases:
+  - hostname
+defaultNamespace: network
+`)
+	talos.SetResource("network", "HostnameStatuses.net.talos.dev", "hostname", `
+hostname: talos-cp-1
+domainname: ""
+`)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourcesDataSourceConfig(talos, "hostname", "hostname"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_resources.test", "resources.#", "1"),
+					resource.TestCheckResourceAttr("data.talos_resources.test", "resources.0.namespace", "network"),
+					resource.TestCheckResourceAttr("data.talos_resources.test", "resources.0.type", "HostnameStatuses.net.talos.dev"),
+					resource.TestCheckResourceAttr("data.talos_resources.test", "resources.0.id", "hostname"),
+					resource.TestCheckResourceAttr("data.talos_resources.test", "resources.0.phase", "running"),
+					resource.TestCheckResourceAttr("data.talos_resources.test", "resources.0.spec_json", `{"domainname":"","hostname":"talos-cp-1"}`),
+				),
+			},
+			{
+				Config:      testAccResourcesDataSourceConfig(talos, "hostname", "other"),
+				ExpectError: regexp.MustCompile("Error reading HostnameStatuses.net.talos.dev resources"),
+			},
+			{
+				Config:      testAccResourcesDataSourceConfig(talos, "members", "talos-cp-1"),
+				ExpectError: regexp.MustCompile("Error resolving resource type"),
+			},
+		},
+	})
+}
+
+func testAccResourcesDataSourceConfig(talos *fakeTalos, resourceType, id string) string {
+	return fmt.Sprintf(`
+data "talos_resources" "test" {%s
+  type = %q
+  id   = %q
+}
+`, talos.testClientConfig(), resourceType, id)
+}
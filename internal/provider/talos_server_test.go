@@ -0,0 +1,857 @@
+package provider
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	cosiv1alpha1 "github.com/cosi-project/runtime/api/v1alpha1"
+	"github.com/talos-systems/talos/pkg/machinery/api/cluster"
+	"github.com/talos-systems/talos/pkg/machinery/api/common"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"github.com/talos-systems/talos/pkg/machinery/api/storage"
+	timeapi "github.com/talos-systems/talos/pkg/machinery/api/time"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeTalos is an in-process Talos API serving scripted responses over
+// mutual TLS on localhost, so that the provider can be tested without Talos
+// nodes. Requests fanned out to nodes through the "nodes" metadata get one
+// message per node, as apid does.
+type fakeTalos struct {
+	machine.UnimplementedMachineServiceServer
+	timeapi.UnimplementedTimeServiceServer
+	storage.UnimplementedStorageServiceServer
+	cluster.UnimplementedClusterServiceServer
+
+	// Endpoint is the address of the server.
+	Endpoint string
+	// CA, Crt and Key are the PEM-encoded credentials of the clients.
+	CA, Crt, Key string
+
+	mu          sync.Mutex
+	calls       map[string]int
+	failures    map[string][]error
+	unreachable map[string]bool
+	version     *machine.VersionInfo
+	kubeconfig  []byte
+	files       map[string][]byte
+	services    []*machine.ServiceInfo
+	configs     [][]byte
+	clockSkew   time.Duration
+	hostname    string
+	members     []*machine.EtcdMember
+	disks       []*storage.Disk
+	resources   []*cosiv1alpha1.Resource
+	health      []string
+	snapshot    []byte
+}
+
+// newFakeTalos starts a fake Talos API, stopped at the end of the test.
+func newFakeTalos(t *testing.T) *fakeTalos {
+	t.Helper()
+
+	ca, caKey := testCertificate(t, nil, nil, func(template *x509.Certificate) {
+		template.Subject = pkix.Name{CommonName: "talos"}
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		template.BasicConstraintsValid = true
+	})
+	serverCrt, serverKey := testCertificate(t, ca, caKey, func(template *x509.Certificate) {
+		template.Subject = pkix.Name{CommonName: "localhost"}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	})
+	clientCrt, clientKey := testCertificate(t, ca, caKey, func(template *x509.Certificate) {
+		template.Subject = pkix.Name{CommonName: "admin", Organization: []string{"os:admin"}}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	})
+
+	serverCert, err := tls.X509KeyPair(encodeCertificate(serverCrt), encodeKey(t, serverKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeTalos{
+		Endpoint:    listener.Addr().String(),
+		CA:          string(encodeCertificate(ca)),
+		Crt:         string(encodeCertificate(clientCrt)),
+		Key:         string(encodeKey(t, clientKey)),
+		calls:       map[string]int{},
+		failures:    map[string][]error{},
+		unreachable: map[string]bool{},
+		version: &machine.VersionInfo{
+			Tag:       "v1.2.3",
+			Sha:       "fcd3e0e",
+			Arch:      "amd64",
+			GoVersion: "go1.19.2",
+		},
+		files: map[string][]byte{
+			bootIDPath: []byte("0fb4d5c4-4f3a-4a3e-9d1b-9a6e6c1f7c01\n"),
+		},
+		services: []*machine.ServiceInfo{
+			{Id: "etcd", State: "Running", Health: &machine.ServiceHealth{Healthy: true}},
+			{Id: "kubelet", State: "Running", Health: &machine.ServiceHealth{Healthy: true}},
+		},
+		hostname: "talos-cp-1",
+		members: []*machine.EtcdMember{
+			{Id: 0x1f3a, Hostname: "talos-cp-1", PeerUrls: []string{"https://10.5.0.2:2380"}, ClientUrls: []string{"https://10.5.0.2:2379"}},
+			{Id: 0x2b7c, Hostname: "talos-cp-2", PeerUrls: []string{"https://10.5.0.3:2380"}, ClientUrls: []string{"https://10.5.0.3:2379"}},
+		},
+		health: []string{
+			"waiting for etcd to be healthy: ...",
+			"waiting for etcd to be healthy: OK",
+			"waiting for all k8s nodes to report ready: OK",
+		},
+	}
+	f.kubeconfig = testKubeconfig("cluster-ca", "client-crt", "client-key")
+	f.snapshot = testEtcdSnapshot(t, 42)
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})))
+	machine.RegisterMachineServiceServer(server, f)
+	timeapi.RegisterTimeServiceServer(server, f)
+	storage.RegisterStorageServiceServer(server, f)
+	cluster.RegisterClusterServiceServer(server, f)
+	cosiv1alpha1.RegisterStateServer(server, &fakeState{f: f})
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return f
+}
+
+// Fail makes the next calls of method fail with errs, one per call.
+func (f *fakeTalos) Fail(method string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[method] = append(f.failures[method], errs...)
+}
+
+// Unreachable makes the requests fanned out to node fail.
+func (f *fakeTalos) Unreachable(node string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.unreachable[node] = true
+}
+
+// Calls returns the number of calls of method.
+func (f *fakeTalos) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[method]
+}
+
+// SetFile sets the content of the file at path, returned by Read.
+func (f *fakeTalos) SetFile(path string, content []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.files[path] = content
+}
+
+// SetClockSkew sets how far the clock of the node is ahead of the local one.
+func (f *fakeTalos) SetClockSkew(skew time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.clockSkew = skew
+}
+
+// SetDisks sets the disks returned by Disks.
+func (f *fakeTalos) SetDisks(disks ...*storage.Disk) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.disks = disks
+}
+
+// SetResource adds a resource with the YAML-encoded spec, returned by the
+// State service.
+func (f *fakeTalos) SetResource(namespace, resourceType, id, spec string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.resources = append(f.resources, &cosiv1alpha1.Resource{
+		Metadata: &cosiv1alpha1.Metadata{Namespace: namespace, Type: resourceType, Id: id, Version: "1", Phase: "running"},
+		Spec:     &cosiv1alpha1.Spec{YamlSpec: spec},
+	})
+}
+
+// EtcdMembers returns the hostnames of the etcd members.
+func (f *fakeTalos) EtcdMembers() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hostnames := make([]string, 0, len(f.members))
+	for _, member := range f.members {
+		hostnames = append(hostnames, member.Hostname)
+	}
+	return hostnames
+}
+
+// AppliedConfigs returns the configurations received by ApplyConfiguration.
+func (f *fakeTalos) AppliedConfigs() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([][]byte{}, f.configs...)
+}
+
+// call records a call of method and returns the error injected for it, if
+// any.
+func (f *fakeTalos) call(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls[method]++
+	if errs := f.failures[method]; len(errs) > 0 {
+		f.failures[method] = errs[1:]
+		return errs[0]
+	}
+
+	return nil
+}
+
+// nodes returns the nodes a request is fanned out to, or nil if it is
+// handled by the endpoint itself.
+func (f *fakeTalos) nodes(ctx context.Context) []string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if nodes := md.Get("nodes"); len(nodes) > 0 {
+		return nodes
+	}
+	return md.Get("node")
+}
+
+func (f *fakeTalos) ApplyConfiguration(ctx context.Context, req *machine.ApplyConfigurationRequest) (*machine.ApplyConfigurationResponse, error) {
+	if err := f.call("ApplyConfiguration"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.configs = append(f.configs, req.Data)
+	f.mu.Unlock()
+
+	return &machine.ApplyConfigurationResponse{
+		Messages: []*machine.ApplyConfiguration{{Mode: req.Mode}},
+	}, nil
+}
+
+func (f *fakeTalos) Bootstrap(ctx context.Context, req *machine.BootstrapRequest) (*machine.BootstrapResponse, error) {
+	if err := f.call("Bootstrap"); err != nil {
+		return nil, err
+	}
+
+	return &machine.BootstrapResponse{
+		Messages: []*machine.Bootstrap{{}},
+	}, nil
+}
+
+// Disks returns the disks set with SetDisks.
+func (f *fakeTalos) Disks(ctx context.Context, _ *emptypb.Empty) (*storage.DisksResponse, error) {
+	if err := f.call("Disks"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &storage.DisksResponse{
+		Messages: []*storage.Disks{{Disks: f.disks}},
+	}, nil
+}
+
+// Dmesg streams a kernel log of testLogLines lines.
+func (f *fakeTalos) Dmesg(_ *machine.DmesgRequest, stream machine.MachineService_DmesgServer) error {
+	if err := f.call("Dmesg"); err != nil {
+		return err
+	}
+
+	return stream.Send(&common.Data{Bytes: testLog("kernel", -1)})
+}
+
+// EtcdLeaveCluster removes the member of the node from the etcd cluster.
+func (f *fakeTalos) EtcdLeaveCluster(ctx context.Context, _ *machine.EtcdLeaveClusterRequest) (*machine.EtcdLeaveClusterResponse, error) {
+	if err := f.call("EtcdLeaveCluster"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.removeMember(f.hostname)
+
+	return &machine.EtcdLeaveClusterResponse{
+		Messages: []*machine.EtcdLeaveCluster{{}},
+	}, nil
+}
+
+func (f *fakeTalos) EtcdMemberList(ctx context.Context, _ *machine.EtcdMemberListRequest) (*machine.EtcdMemberListResponse, error) {
+	if err := f.call("EtcdMemberList"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &machine.EtcdMemberListResponse{
+		Messages: []*machine.EtcdMembers{{Members: append([]*machine.EtcdMember{}, f.members...)}},
+	}, nil
+}
+
+func (f *fakeTalos) EtcdRemoveMember(ctx context.Context, req *machine.EtcdRemoveMemberRequest) (*machine.EtcdRemoveMemberResponse, error) {
+	if err := f.call("EtcdRemoveMember"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.removeMember(req.Member) {
+		return nil, status.Errorf(codes.NotFound, "member %q not found", req.Member)
+	}
+
+	return &machine.EtcdRemoveMemberResponse{
+		Messages: []*machine.EtcdRemoveMember{{}},
+	}, nil
+}
+
+// removeMember removes the etcd member with the given hostname, and reports
+// whether there was one. f.mu must be held.
+func (f *fakeTalos) removeMember(hostname string) bool {
+	for i, member := range f.members {
+		if member.Hostname == hostname {
+			f.members = append(f.members[:i:i], f.members[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeTalos) EtcdSnapshot(_ *machine.EtcdSnapshotRequest, stream machine.MachineService_EtcdSnapshotServer) error {
+	if err := f.call("EtcdSnapshot"); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	snapshot := f.snapshot
+	f.mu.Unlock()
+
+	// Sent in chunks, as apid does
+	for len(snapshot) > 0 {
+		n := len(snapshot)
+		if n > 4096 {
+			n = 4096
+		}
+		if err := stream.Send(&common.Data{Bytes: snapshot[:n]}); err != nil {
+			return err
+		}
+		snapshot = snapshot[n:]
+	}
+
+	return nil
+}
+
+// HealthCheck reports the progress messages of the checks, all passing.
+func (f *fakeTalos) HealthCheck(_ *cluster.HealthCheckRequest, stream cluster.ClusterService_HealthCheckServer) error {
+	if err := f.call("HealthCheck"); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	messages := f.health
+	f.mu.Unlock()
+
+	for _, message := range messages {
+		if err := stream.Send(&cluster.HealthCheckProgress{Message: message}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeTalos) Hostname(ctx context.Context, _ *emptypb.Empty) (*machine.HostnameResponse, error) {
+	if err := f.call("Hostname"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &machine.HostnameResponse{
+		Messages: []*machine.Hostname{{Hostname: f.hostname}},
+	}, nil
+}
+
+func (f *fakeTalos) Kubeconfig(_ *emptypb.Empty, stream machine.MachineService_KubeconfigServer) error {
+	if err := f.call("Kubeconfig"); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	kubeconfig := f.kubeconfig
+	f.mu.Unlock()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: "kubeconfig", Mode: 0o600, Size: int64(len(kubeconfig))}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(kubeconfig); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return stream.Send(&common.Data{Bytes: buf.Bytes()})
+}
+
+// Logs streams the last lines of a log of testLogLines lines for the known
+// services.
+func (f *fakeTalos) Logs(req *machine.LogsRequest, stream machine.MachineService_LogsServer) error {
+	if err := f.call("Logs"); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	known := false
+	for _, svc := range f.services {
+		known = known || svc.Id == req.Id
+	}
+	f.mu.Unlock()
+	if !known {
+		return status.Errorf(codes.NotFound, "log %q was not registered", req.Id)
+	}
+
+	return stream.Send(&common.Data{Bytes: testLog(req.Id, req.TailLines)})
+}
+
+func (f *fakeTalos) Read(req *machine.ReadRequest, stream machine.MachineService_ReadServer) error {
+	if err := f.call("Read"); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	content, ok := f.files[req.Path]
+	f.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "open %s: no such file or directory", req.Path)
+	}
+
+	return stream.Send(&common.Data{Bytes: content})
+}
+
+// Reboot reboots the node instantly, with a new boot ID.
+func (f *fakeTalos) Reboot(ctx context.Context, _ *machine.RebootRequest) (*machine.RebootResponse, error) {
+	if err := f.call("Reboot"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.files[bootIDPath] = []byte(fmt.Sprintf("0fb4d5c4-4f3a-4a3e-9d1b-%012x\n", f.calls["Reboot"]))
+
+	return &machine.RebootResponse{
+		Messages: []*machine.Reboot{{}},
+	}, nil
+}
+
+// ServiceList reports the services. As Talos does, a service whose health is
+// unknown runs its first health check, which passes, before the next call.
+func (f *fakeTalos) ServiceList(ctx context.Context, _ *emptypb.Empty) (*machine.ServiceListResponse, error) {
+	if err := f.call("ServiceList"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	services := make([]*machine.ServiceInfo, 0, len(f.services))
+	for _, svc := range f.services {
+		services = append(services, proto.Clone(svc).(*machine.ServiceInfo))
+		if svc.Health != nil && svc.Health.Unknown {
+			svc.Health = &machine.ServiceHealth{Healthy: true}
+		}
+	}
+
+	return &machine.ServiceListResponse{
+		Messages: []*machine.ServiceList{{Services: services}},
+	}, nil
+}
+
+// ServiceRestart restarts a service, whose health is unknown until its next
+// health check.
+func (f *fakeTalos) ServiceRestart(ctx context.Context, req *machine.ServiceRestartRequest) (*machine.ServiceRestartResponse, error) {
+	if err := f.call("ServiceRestart"); err != nil {
+		return nil, err
+	}
+
+	if err := f.setServiceState(req.Id, "Running"); err != nil {
+		return nil, err
+	}
+
+	return &machine.ServiceRestartResponse{
+		Messages: []*machine.ServiceRestart{{Resp: fmt.Sprintf("Service %q restarted", req.Id)}},
+	}, nil
+}
+
+func (f *fakeTalos) ServiceStart(ctx context.Context, req *machine.ServiceStartRequest) (*machine.ServiceStartResponse, error) {
+	if err := f.call("ServiceStart"); err != nil {
+		return nil, err
+	}
+
+	if err := f.setServiceState(req.Id, "Running"); err != nil {
+		return nil, err
+	}
+
+	return &machine.ServiceStartResponse{
+		Messages: []*machine.ServiceStart{{Resp: fmt.Sprintf("Service %q started", req.Id)}},
+	}, nil
+}
+
+func (f *fakeTalos) ServiceStop(ctx context.Context, req *machine.ServiceStopRequest) (*machine.ServiceStopResponse, error) {
+	if err := f.call("ServiceStop"); err != nil {
+		return nil, err
+	}
+
+	if err := f.setServiceState(req.Id, "Finished"); err != nil {
+		return nil, err
+	}
+
+	return &machine.ServiceStopResponse{
+		Messages: []*machine.ServiceStop{{Resp: fmt.Sprintf("Service %q stopped", req.Id)}},
+	}, nil
+}
+
+// setServiceState moves the service with the given ID to state, recording an
+// event. Running services have an unknown health until their next check.
+func (f *fakeTalos) setServiceState(id, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, svc := range f.services {
+		if svc.Id != id {
+			continue
+		}
+		svc.State = state
+		svc.Health = nil
+		if state == "Running" {
+			svc.Health = &machine.ServiceHealth{Unknown: true}
+		}
+		if svc.Events == nil {
+			svc.Events = &machine.ServiceEvents{}
+		}
+		svc.Events.Events = append(svc.Events.Events, &machine.ServiceEvent{
+			Msg:   fmt.Sprintf("Service %s", state),
+			State: state,
+			Ts:    timestamppb.Now(),
+		})
+		return nil
+	}
+
+	return status.Errorf(codes.NotFound, "service %q not found", id)
+}
+
+func (f *fakeTalos) Time(ctx context.Context, _ *emptypb.Empty) (*timeapi.TimeResponse, error) {
+	if err := f.call("Time"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &timeapi.TimeResponse{
+		Messages: []*timeapi.Time{{Localtime: timestamppb.New(time.Now().Add(f.clockSkew))}},
+	}, nil
+}
+
+// Upgrade upgrades the node instantly to the tag of the image.
+func (f *fakeTalos) Upgrade(ctx context.Context, req *machine.UpgradeRequest) (*machine.UpgradeResponse, error) {
+	if err := f.call("Upgrade"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	version := proto.Clone(f.version).(*machine.VersionInfo)
+	version.Tag = imageTag(req.Image)
+	f.version = version
+
+	return &machine.UpgradeResponse{
+		Messages: []*machine.Upgrade{{Ack: "Upgrade request received"}},
+	}, nil
+}
+
+func (f *fakeTalos) Version(ctx context.Context, _ *emptypb.Empty) (*machine.VersionResponse, error) {
+	if err := f.call("Version"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	nodes := f.nodes(ctx)
+	if len(nodes) == 0 {
+		return &machine.VersionResponse{
+			Messages: []*machine.Version{{Version: f.version}},
+		}, nil
+	}
+
+	resp := &machine.VersionResponse{}
+	for _, node := range nodes {
+		if f.unreachable[node] {
+			resp.Messages = append(resp.Messages, &machine.Version{
+				Metadata: &common.Metadata{Hostname: node, Error: "connection refused"},
+			})
+			continue
+		}
+		resp.Messages = append(resp.Messages, &machine.Version{
+			Metadata: &common.Metadata{Hostname: node},
+			Version:  f.version,
+		})
+	}
+
+	return resp, nil
+}
+
+// fakeState is the COSI State service of a fakeTalos, serving the resources
+// set with SetResource. It is a separate server as its methods clash with the
+// ones of the machine service.
+type fakeState struct {
+	cosiv1alpha1.UnimplementedStateServer
+
+	f *fakeTalos
+}
+
+// Get returns the resource set with SetResource.
+func (s *fakeState) Get(ctx context.Context, req *cosiv1alpha1.GetRequest) (*cosiv1alpha1.GetResponse, error) {
+	if err := s.f.call("Get"); err != nil {
+		return nil, err
+	}
+
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+
+	for _, res := range s.f.resources {
+		if res.Metadata.Namespace == req.Namespace && res.Metadata.Type == req.Type && res.Metadata.Id == req.Id {
+			return &cosiv1alpha1.GetResponse{Resource: res}, nil
+		}
+	}
+
+	return nil, status.Errorf(codes.NotFound, "resource %s/%s/%s not found", req.Namespace, req.Type, req.Id)
+}
+
+// List returns the resources set with SetResource.
+func (s *fakeState) List(req *cosiv1alpha1.ListRequest, stream cosiv1alpha1.State_ListServer) error {
+	if err := s.f.call("List"); err != nil {
+		return err
+	}
+
+	s.f.mu.Lock()
+	resources := append([]*cosiv1alpha1.Resource{}, s.f.resources...)
+	s.f.mu.Unlock()
+
+	for _, res := range resources {
+		if res.Metadata.Namespace != req.Namespace || res.Metadata.Type != req.Type {
+			continue
+		}
+		if err := stream.Send(&cosiv1alpha1.ListResponse{Resource: res}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// testCertificate returns a certificate signed by parent, or self-signed if
+// parent is nil, and its key.
+func testCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, configure func(*x509.Certificate)) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	configure(template)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+// encodeCertificate returns cert in PEM format.
+func encodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// encodeKey returns key in PEM format.
+func encodeKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+// testEtcdSnapshot returns an etcd snapshot at the given revision: a bolt
+// database whose key bucket holds a key of that revision.
+func testEtcdSnapshot(t *testing.T, revision uint64) []byte {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("key"))
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 17)
+		binary.BigEndian.PutUint64(key, revision)
+		key[8] = '_'
+		return bucket.Put(key, []byte("value"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+// testKubeconfig returns a kubeconfig file with the given credentials.
+func testKubeconfig(clusterCa, clientCrt, clientKey string) []byte {
+	return []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+    certificate-authority-data: %s
+users:
+- name: admin@test
+  user:
+    client-certificate-data: %s
+    client-key-data: %s
+contexts:
+- name: admin@test
+  context:
+    cluster: test
+    user: admin@test
+current-context: admin@test
+`,
+		base64.StdEncoding.EncodeToString([]byte(clusterCa)),
+		base64.StdEncoding.EncodeToString([]byte(clientCrt)),
+		base64.StdEncoding.EncodeToString([]byte(clientKey)),
+	))
+}
+
+// testClientConfig returns the client attributes to connect to f, in HCL.
+// testLogLines is the number of lines of the logs of fakeTalos.
+const testLogLines = 25
+
+// testLog returns the last tailLines lines of a log, or all of them if
+// tailLines is -1.
+func testLog(name string, tailLines int32) []byte {
+	first := 1
+	if tailLines >= 0 && tailLines < testLogLines {
+		first = testLogLines - int(tailLines) + 1
+	}
+
+	var buf bytes.Buffer
+	for i := first; i <= testLogLines; i++ {
+		fmt.Fprintf(&buf, "%s line %d\n", name, i)
+	}
+	return buf.Bytes()
+}
+
+func (f *fakeTalos) testClientConfig() string {
+	return fmt.Sprintf(`
+  endpoint    = %q
+  machine_ca  = %q
+  machine_crt = %q
+  machine_key = %q
+`, f.Endpoint, f.CA, f.Crt, f.Key)
+}
@@ -0,0 +1,264 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var _ datasource.DataSource = &VersionDataSource{}
+
+func NewVersionDataSource() datasource.DataSource {
+	return &VersionDataSource{}
+}
+
+type VersionDataSource struct{}
+
+type VersionDataSourceModel struct {
+	ID         types.String       `tfsdk:"id"`
+	Endpoint   types.String       `tfsdk:"endpoint"`
+	Endpoints  types.List         `tfsdk:"endpoints"`
+	MachineCa  types.String       `tfsdk:"machine_ca"`
+	MachineCrt types.String       `tfsdk:"machine_crt"`
+	MachineKey types.String       `tfsdk:"machine_key"`
+	Nodes      types.List         `tfsdk:"nodes"`
+	Versions   []NodeVersionModel `tfsdk:"versions"`
+}
+
+type NodeVersionModel struct {
+	Node            types.String `tfsdk:"node"`
+	Tag             types.String `tfsdk:"tag"`
+	Sha             types.String `tfsdk:"sha"`
+	Arch            types.String `tfsdk:"arch"`
+	GoVersion       types.String `tfsdk:"go_version"`
+	Platform        types.String `tfsdk:"platform"`
+	Mode            types.String `tfsdk:"mode"`
+	MaintenanceMode types.Bool   `tfsdk:"maintenance_mode"`
+}
+
+func (d *VersionDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_version"
+}
+
+func (d *VersionDataSource) GetSchema(ctx context.Context) (tfsdk.Schema, diag.Diagnostics) {
+	return tfsdk.Schema{
+		MarkdownDescription: "Read the Talos version running on nodes, including nodes in maintenance mode.",
+
+		Attributes: withClientAttributes(map[string]tfsdk.Attribute{
+			"id": {
+				Computed:            true,
+				MarkdownDescription: "Identifier, set to `endpoint`.",
+				Type:                types.StringType,
+			},
+			"nodes": {
+				MarkdownDescription: "Nodes to query through `endpoint` with a single request (defaults to the node at `endpoint`). The nodes that cannot be queried through `endpoint` are tried directly at their address, bypassing `endpoint`, in case they are in maintenance mode. An error is reported for each node that cannot be queried either way.",
+				Optional:            true,
+				Type: types.ListType{
+					ElemType: types.StringType,
+				},
+			},
+			"versions": {
+				Attributes: tfsdk.ListNestedAttributes(map[string]tfsdk.Attribute{
+					"node": {
+						Computed:            true,
+						MarkdownDescription: "Address of the node.",
+						Type:                types.StringType,
+					},
+					"tag": {
+						Computed:            true,
+						MarkdownDescription: "Talos version tag (e.g. `v1.2.3`).",
+						Type:                types.StringType,
+					},
+					"sha": {
+						Computed:            true,
+						MarkdownDescription: "Git SHA of the Talos build.",
+						Type:                types.StringType,
+					},
+					"arch": {
+						Computed:            true,
+						MarkdownDescription: "CPU architecture of the node.",
+						Type:                types.StringType,
+					},
+					"go_version": {
+						Computed:            true,
+						MarkdownDescription: "Go version Talos was built with.",
+						Type:                types.StringType,
+					},
+					"platform": {
+						Computed:            true,
+						MarkdownDescription: "Name of the platform the node runs on (e.g. `metal`, `openstack`).",
+						Type:                types.StringType,
+					},
+					"mode": {
+						Computed:            true,
+						MarkdownDescription: "Mode of the platform (e.g. `cloud`, `metal`).",
+						Type:                types.StringType,
+					},
+					"maintenance_mode": {
+						Computed:            true,
+						MarkdownDescription: "Whether the node is in maintenance mode, waiting for a configuration. Nodes in maintenance mode whose maintenance API does not report the version are reported as errors.",
+						Type:                types.BoolType,
+					},
+				}),
+				Computed:            true,
+				MarkdownDescription: "Version information of each node.",
+			},
+		}),
+	}, nil
+}
+
+func (d *VersionDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		clientCredentialsValidator{readerRoles},
+	}
+}
+
+func (d *VersionDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data *VersionDataSourceModel
+
+	// Read Terraform configuration data into the model
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var nodes []string
+	resp.Diagnostics.Append(data.Nodes.ElementsAs(ctx, &nodes, false)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if len(nodes) == 0 {
+		nodes = []string{""}
+	}
+
+	endpoints, diags := clientEndpoints(ctx, data.Endpoint, data.Endpoints)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	tlsCredentials, diags := clientCredentials(data.MachineCa, data.MachineCrt, data.MachineKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// The connection is not blocking, so that errors are reported by the
+	// requests and nodes in maintenance mode can be detected.
+	conn, err := dial(ctx, endpoints, tlsCredentials)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating gRPC connection",
+			err.Error(),
+		)
+		return
+	}
+	defer conn.Close()
+	client := machine.NewMachineServiceClient(conn)
+
+	// A single request is fanned out to the nodes, each of them reporting
+	// its version or its error separately.
+	var versions map[string]*machine.Version
+	var errs map[string]error
+	versionResp, err := client.Version(nodesContext(ctx, nodes), &emptypb.Empty{})
+	if err != nil {
+		errs = nodeErrors(nodes, err)
+	} else {
+		versions, errs = splitNodeMessages(nodes, versionResp.Messages)
+	}
+
+	data.Versions = nil
+	for _, node := range nodes {
+		address := node
+		if address == "" {
+			address = data.Endpoint.Value
+		}
+
+		msg, maintenance := versions[node], false
+		if err, ok := errs[node]; ok {
+			msg, err = readMaintenanceVersion(ctx, address, err)
+			if err != nil {
+				resp.Diagnostics.AddError(
+					fmt.Sprintf("Error reading version of node %s", address),
+					err.Error(),
+				)
+				continue
+			}
+			maintenance = true
+		}
+
+		data.Versions = append(data.Versions, nodeVersionModel(address, msg, maintenance))
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Write logs using the tflog package
+	// Documentation: https://terraform.io/plugin/log
+	tflog.Trace(ctx, "read a Talos version data source")
+
+	// Save data into Terraform state
+	data.ID = types.String{Value: data.Endpoint.Value}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// readMaintenanceVersion reads the version of the node at address through
+// its maintenance API, dialed directly rather than through the endpoint,
+// after the authenticated Talos API failed with err. If the maintenance API
+// does not report the version either, err is returned along with the reason.
+func readMaintenanceVersion(ctx context.Context, address string, err error) (*machine.Version, error) {
+	maintenanceResp, maintenanceErr := maintenanceVersion(ctx, address)
+	switch {
+	case status.Code(maintenanceErr) == codes.Unimplemented:
+		return nil, fmt.Errorf("%w (the node may be in maintenance mode, but its maintenance API does not report the version)", err)
+	case maintenanceErr != nil:
+		return nil, fmt.Errorf("%w (maintenance API: %s)", err, maintenanceErr)
+	case len(maintenanceResp.Messages) == 0:
+		return nil, fmt.Errorf("%w (maintenance API: empty version response)", err)
+	}
+
+	tflog.Debug(ctx, "node is in maintenance mode", map[string]interface{}{"node": address, "error": err.Error()})
+
+	return maintenanceResp.Messages[0], nil
+}
+
+// nodeVersionModel returns the version information of the node at address.
+func nodeVersionModel(address string, msg *machine.Version, maintenance bool) NodeVersionModel {
+	info := msg.GetVersion()
+	platform := msg.GetPlatform()
+
+	return NodeVersionModel{
+		Node:            types.String{Value: address},
+		Tag:             types.String{Value: info.GetTag()},
+		Sha:             types.String{Value: info.GetSha()},
+		Arch:            types.String{Value: info.GetArch()},
+		GoVersion:       types.String{Value: info.GetGoVersion()},
+		Platform:        types.String{Value: platform.GetName()},
+		Mode:            types.String{Value: platform.GetMode()},
+		MaintenanceMode: types.Bool{Value: maintenance},
+	}
+}
+
+// maintenanceVersion reads the version of the node at address through its
+// maintenance API. Older Talos versions do not implement it, in which case an
+// Unimplemented error is returned.
+func maintenanceVersion(ctx context.Context, address string) (*machine.VersionResponse, error) {
+	conn, err := dialTalosMaintenance(ctx, []string{address})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return machine.NewMachineServiceClient(conn).Version(ctx, &emptypb.Empty{})
+}
@@ -0,0 +1,140 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/talos-systems/talos/pkg/machinery/api/machine"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestAccVersionDataSource(t *testing.T) {
+	talos := newFakeTalos(t)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+data "talos_version" "test" {%s}
+`, talos.testClientConfig()),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_version.test", "versions.#", "1"),
+					resource.TestCheckResourceAttr("data.talos_version.test", "versions.0.node", talos.Endpoint),
+					resource.TestCheckResourceAttr("data.talos_version.test", "versions.0.tag", "v1.2.3"),
+					resource.TestCheckResourceAttr("data.talos_version.test", "versions.0.maintenance_mode", "false"),
+				),
+			},
+			{
+				Config: fmt.Sprintf(`
+data "talos_version" "test" {%s
+  nodes = ["127.0.0.2", "127.0.0.3"]
+}
+`, talos.testClientConfig()),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.talos_version.test", "versions.#", "2"),
+					resource.TestCheckResourceAttr("data.talos_version.test", "versions.1.node", "127.0.0.3"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccVersionDataSource_unreachableNode(t *testing.T) {
+	talos := newFakeTalos(t)
+	talos.Unreachable("127.0.0.3")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                 func() { testAccPreCheck(t) },
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+data "talos_version" "test" {%s
+  nodes = ["127.0.0.2", "127.0.0.3"]
+}
+`, talos.testClientConfig()),
+				ExpectError: regexp.MustCompile("Error reading version of node 127.0.0.3"),
+			},
+		},
+	})
+}
+
+func TestReadMaintenanceVersion(t *testing.T) {
+	apiErr := errors.New("x509: certificate signed by unknown authority")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	version, err := readMaintenanceVersion(ctx, startMaintenanceServer(t, &fakeMaintenance{}), apiErr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.GetVersion().GetTag() != "v1.2.3" {
+		t.Errorf("unexpected maintenance version %v", version)
+	}
+
+	for name, address := range map[string]string{
+		"unimplemented": startMaintenanceServer(t, &machine.UnimplementedMachineServiceServer{}),
+		"unreachable":   "127.0.0.1:1",
+	} {
+		_, err := readMaintenanceVersion(ctx, address, apiErr)
+		if !errors.Is(err, apiErr) {
+			t.Errorf("%s: error %v does not wrap the error of the Talos API", name, err)
+		}
+		if name == "unimplemented" && (err == nil || !strings.Contains(err.Error(), "does not report the version")) {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+// fakeMaintenance is the maintenance API of an unconfigured node.
+type fakeMaintenance struct {
+	machine.UnimplementedMachineServiceServer
+}
+
+func (m *fakeMaintenance) Version(ctx context.Context, _ *emptypb.Empty) (*machine.VersionResponse, error) {
+	return &machine.VersionResponse{
+		Messages: []*machine.Version{{Version: &machine.VersionInfo{Tag: "v1.2.3"}}},
+	}, nil
+}
+
+// startMaintenanceServer starts a Talos maintenance API, which does not
+// authenticate clients, and returns its address.
+func startMaintenanceServer(t *testing.T, server machine.MachineServiceServer) string {
+	t.Helper()
+
+	crt, key := testCertificate(t, nil, nil, func(template *x509.Certificate) {
+		template.Subject = pkix.Name{CommonName: "maintenance"}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	})
+	cert, err := tls.X509KeyPair(encodeCertificate(crt), encodeKey(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})))
+	machine.RegisterMachineServiceServer(s, server)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	return listener.Addr().String()
+}
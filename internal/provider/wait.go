@@ -0,0 +1,39 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultPollInterval is the interval between two consecutive checks while
+// waiting for a node to reach the desired state.
+const defaultPollInterval = 5 * time.Second
+
+// waitUntil calls check every interval until it reports done, or until ctx is
+// done. Errors returned by check are considered transient (e.g. the node is
+// rebooting), and the last one is reported if ctx expires.
+func waitUntil(ctx context.Context, interval time.Duration, check func(ctx context.Context) (bool, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		done, err := check(ctx)
+		if err == nil && done {
+			return nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w (last error: %s)", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitUntil(t *testing.T) {
+	calls := 0
+	err := waitUntil(context.Background(), time.Millisecond, func(ctx context.Context) (bool, error) {
+		calls++
+		switch calls {
+		case 1:
+			return false, errors.New("connection refused")
+		case 2:
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("check called %d times, expected 3", calls)
+	}
+}
+
+func TestWaitUntilTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := waitUntil(ctx, time.Millisecond, func(ctx context.Context) (bool, error) {
+		return false, errors.New("connection refused")
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if err == nil || !strings.Contains(err.Error(), "last error: connection refused") {
+		t.Errorf("error %v does not report the last error of the check", err)
+	}
+}